@@ -0,0 +1,38 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+//go:build go1.23
+// +build go1.23
+
+package parts
+
+import (
+	"context"
+	"iter"
+)
+
+// All returns an iterator over the paths and modes of the files in
+// paths in "run-parts" order, i.e., the files listed by Readdir, for
+// use with range-over-func:
+//
+//	for path, mode := range p.All() {
+//		...
+//	}
+//
+// The files are listed when iteration begins, each time it begins.
+// Nothing is yielded if the traversal fails; use Readdir to retrieve
+// the error. Breaking out of the loop early releases all resources.
+func (p *Parts) All() iter.Seq2[string, FileMode] {
+	return func(yield func(string, FileMode) bool) {
+		entries, err := p.readdir(context.Background(), 0)
+		if err != nil {
+			return
+		}
+		for _, e := range entries {
+			if !yield(e.path, e.mode) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+//go:build go1.23
+// +build go1.23
+
+package parts_test
+
+import (
+	"testing"
+
+	"github.com/apatters/go-parts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAll(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	fileNames, err := p.Readdirnames(0)
+	require.NoError(t, err)
+	infos, err := p.Readdir(0)
+	require.NoError(t, err)
+	var paths []string
+	var modes []parts.FileMode
+	for path, mode := range p.All() {
+		paths = append(paths, path)
+		modes = append(modes, mode)
+	}
+	t.Logf("paths: %s", paths)
+	assert.Equal(t, fileNames, paths)
+	require.Len(t, modes, len(infos))
+	for i, info := range infos {
+		assert.Equal(t, info.Mode(), modes[i])
+	}
+
+	// Breaking out early stops the iteration.
+	count := 0
+	for range p.All() {
+		count++
+		if count == 2 {
+			break
+		}
+	}
+	assert.Equal(t, 2, count)
+
+	p = parts.NewParts([]string{"testdata/nonexistent"}, config)
+	for path := range p.All() {
+		t.Errorf("unexpected path: %s", path)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts
+
+import (
+	"time"
+)
+
+// cachedContent holds the contents of a file along with the
+// modification time and size used to detect changes to it.
+type cachedContent struct {
+	modTime time.Time
+	size    int64
+	content []byte
+}
+
+// contentCache maps file paths to their cached contents.
+type contentCache map[string]cachedContent
+
+// get returns the cached contents of the file described by e. The
+// cached contents are only returned if the file has not changed since
+// it was cached.
+func (c contentCache) get(e entry) ([]byte, bool) {
+	cached, ok := c[e.path]
+	if !ok {
+		return nil, false
+	}
+	if !cached.modTime.Equal(e.info.ModTime()) || cached.size != e.info.Size() {
+		return nil, false
+	}
+
+	return cached.content, true
+}
+
+// put saves the contents of the file described by e.
+func (c contentCache) put(e entry, content []byte) {
+	c[e.path] = cachedContent{
+		modTime: e.info.ModTime(),
+		size:    e.info.Size(),
+		content: content,
+	}
+}
+
+// prune removes the contents of files not described by entries, e.g.,
+// files that were removed or are no longer selected.
+func (c contentCache) prune(entries []entry) {
+	keep := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		keep[e.path] = true
+	}
+	for path := range c {
+		if !keep[path] {
+			delete(c, path)
+		}
+	}
+}
+
+// cachedDir holds the names of the files in a directory along with
+// the directory's modification time used to detect changes to it.
+type cachedDir struct {
+	modTime   time.Time
+	fileNames []string
+}
+
+// statCache maps directory paths to their cached file names.
+type statCache map[string]cachedDir
+
+// get returns the cached file names for dir. They are only returned if
+// the directory has not been modified since they were cached.
+func (c statCache) get(dir string, modTime time.Time) ([]string, bool) {
+	cached, ok := c[dir]
+	if !ok || !cached.modTime.Equal(modTime) {
+		return nil, false
+	}
+
+	return cached.fileNames, true
+}
+
+// put saves the file names for dir.
+func (c statCache) put(dir string, modTime time.Time, fileNames []string) {
+	c[dir] = cachedDir{
+		modTime:   modTime,
+		fileNames: fileNames,
+	}
+}
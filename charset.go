@@ -0,0 +1,141 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// newCharsetReader returns a reader that transcodes the contents of r
+// from charset to UTF-8. The supported charsets are "utf-8", which is
+// passed through, "utf-16le", "utf-16be", "utf-16", which requires a
+// byte order mark and defaults to big endian without one, and
+// "iso-8859-1", also known as "latin1". The names are case
+// insensitive.
+func newCharsetReader(r io.Reader, charset string) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "utf-8", "utf8":
+		return r, nil
+	case "utf-16le", "utf16le":
+		return &utf16Reader{r: r, order: binary.LittleEndian}, nil
+	case "utf-16be", "utf16be":
+		return &utf16Reader{r: r, order: binary.BigEndian}, nil
+	case "utf-16", "utf16":
+		return &utf16Reader{r: r}, nil
+	case "iso-8859-1", "latin1":
+		return &latin1Reader{r: r}, nil
+	}
+
+	return nil, fmt.Errorf("parts: unsupported charset: %q", charset)
+}
+
+// utf16Reader transcodes UTF-16 to UTF-8. A leading byte order mark is
+// removed and, if order is nil, used to determine the byte order.
+// Invalid input is replaced by utf8.RuneError.
+type utf16Reader struct {
+	r       io.Reader
+	order   binary.ByteOrder
+	started bool
+	in      []byte // Undecoded input.
+	out     []byte // Decoded output not yet returned.
+	err     error
+}
+
+func (u *utf16Reader) Read(b []byte) (int, error) {
+	for len(u.out) == 0 {
+		if u.err != nil {
+			return 0, u.err
+		}
+		var chunk [4096]byte
+		n, err := u.r.Read(chunk[:])
+		u.in = append(u.in, chunk[:n]...)
+		u.decode(err != nil)
+		u.err = err
+	}
+	n := copy(b, u.out)
+	u.out = u.out[n:]
+
+	return n, nil
+}
+
+// decode moves as much of the input as possible to the output. All of
+// it is moved if final is set.
+func (u *utf16Reader) decode(final bool) {
+	if !u.started {
+		if len(u.in) < 2 && !final {
+			return
+		}
+		u.started = true
+		u.readBOM()
+	}
+	units := make([]uint16, 0, len(u.in)/2)
+	for i := 0; i+1 < len(u.in); i += 2 {
+		units = append(units, u.order.Uint16(u.in[i:]))
+	}
+	rest := u.in[2*len(units):]
+	// Keep a trailing high surrogate until its pair arrives.
+	if !final && len(units) > 0 && utf16.IsSurrogate(rune(units[len(units)-1])) && units[len(units)-1] < 0xdc00 {
+		units = units[:len(units)-1]
+		rest = u.in[2*len(units):]
+	}
+	u.out = append(u.out, string(utf16.Decode(units))...)
+	u.in = append(u.in[:0], rest...)
+	if final && len(u.in) > 0 {
+		u.out = append(u.out, string(utf8.RuneError)...)
+		u.in = u.in[:0]
+	}
+}
+
+// readBOM removes a leading byte order mark from the input, setting
+// the byte order from it if it is not already known.
+func (u *utf16Reader) readBOM() {
+	var order binary.ByteOrder
+	switch {
+	case len(u.in) < 2:
+	case u.in[0] == 0xfe && u.in[1] == 0xff:
+		order = binary.BigEndian
+	case u.in[0] == 0xff && u.in[1] == 0xfe:
+		order = binary.LittleEndian
+	}
+	switch {
+	case u.order == nil && order == nil:
+		u.order = binary.BigEndian
+		return
+	case u.order == nil:
+		u.order = order
+	case u.order != order:
+		return
+	}
+	u.in = u.in[2:]
+}
+
+// latin1Reader transcodes ISO-8859-1 to UTF-8.
+type latin1Reader struct {
+	r   io.Reader
+	out []byte // Decoded output not yet returned.
+}
+
+func (l *latin1Reader) Read(b []byte) (int, error) {
+	if len(l.out) == 0 {
+		// Each byte is encoded in at most two bytes.
+		chunk := make([]byte, (len(b)+1)/2)
+		n, err := l.r.Read(chunk)
+		for _, c := range chunk[:n] {
+			l.out = append(l.out, string(rune(c))...)
+		}
+		if len(l.out) == 0 {
+			return 0, err
+		}
+	}
+	n := copy(b, l.out)
+	l.out = l.out[n:]
+
+	return n, nil
+}
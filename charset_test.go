@@ -0,0 +1,102 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts_test
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+
+	"github.com/apatters/go-parts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeUTF16 encodes s as UTF-16 in the given byte order, preceded by
+// a byte order mark if bom is set.
+func encodeUTF16(s string, order binary.ByteOrder, bom bool) []byte {
+	units := utf16.Encode([]rune(s))
+	if bom {
+		units = append([]uint16{0xfeff}, units...)
+	}
+	b := make([]byte, 2*len(units))
+	for i, unit := range units {
+		order.PutUint16(b[2*i:], unit)
+	}
+
+	return b
+}
+
+func TestReadCharset(t *testing.T) {
+	const text = "a = café \U0001F600\n"
+	for _, test := range []struct {
+		charset  string
+		contents []byte
+		expected string
+	}{
+		{"utf-16le", encodeUTF16(text, binary.LittleEndian, true), text},
+		{"utf-16le", encodeUTF16(text, binary.LittleEndian, false), text},
+		{"UTF-16BE", encodeUTF16(text, binary.BigEndian, false), text},
+		{"utf-16", encodeUTF16(text, binary.LittleEndian, true), text},
+		{"utf-16", encodeUTF16(text, binary.BigEndian, true), text},
+		{"utf-16", encodeUTF16(text, binary.BigEndian, false), text},
+		{"utf-16le", append(encodeUTF16("ab", binary.LittleEndian, false), 'c'), "ab�"},
+		{"utf-16le", encodeUTF16("a", binary.LittleEndian, false)[:1], "�"},
+		{"utf-16le", nil, ""},
+		{"iso-8859-1", []byte("caf\xe9\n"), "café\n"},
+		{"utf-8", []byte(text), text},
+	} {
+		dir, err := ioutil.TempDir("", "parts")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "10-file.conf"), test.contents, 0644))
+
+		config := parts.NewDefaultConfig()
+		config.Charset = test.charset
+		p := parts.NewParts([]string{dir}, config)
+		b, err := p.Bytes()
+		t.Logf("charset: %s, contents: %q", test.charset, b)
+		t.Logf("err: %v", err)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, string(b))
+
+		// Feed the input a byte at a time to split the code
+		// units and surrogate pairs across reads.
+		parts.SetOpenFunc(p, func(name string) (io.ReadCloser, error) {
+			file, err := os.Open(name)
+			if err != nil {
+				return nil, err
+			}
+			return struct {
+				io.Reader
+				io.Closer
+			}{iotest.OneByteReader(file), file}, nil
+		})
+		b, err = ioutil.ReadAll(iotest.OneByteReader(p))
+		require.NoError(t, err)
+		require.NoError(t, p.Close())
+		assert.Equal(t, test.expected, string(b))
+
+		// The length of the transcoded contents is unknown.
+		length, err := p.Len()
+		t.Logf("length: %d, err: %v", length, err)
+		assert.Error(t, err)
+	}
+}
+
+func TestReadCharsetUnsupported(t *testing.T) {
+	config := parts.NewDefaultConfig()
+	config.Charset = "ebcdic"
+	p := parts.NewParts(testDataPaths, config)
+	b, err := p.Bytes()
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+	assert.Nil(t, b)
+}
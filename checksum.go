@@ -0,0 +1,95 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts
+
+import (
+	"context"
+	_ "crypto/md5"  // Register crypto.MD5 for HashAlgo.
+	_ "crypto/sha1" // Register crypto.SHA1 for HashAlgo.
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// newHash returns a new hash.Hash using HashAlgo, or SHA-256 if it is
+// not set.
+func (p *Parts) newHash() (hash.Hash, error) {
+	if p.Config.HashAlgo == 0 {
+		return sha256.New(), nil
+	}
+	if !p.Config.HashAlgo.Available() {
+		return nil, fmt.Errorf("parts: hash function %d is not available", p.Config.HashAlgo)
+	}
+
+	return p.Config.HashAlgo.New(), nil
+}
+
+// Checksums returns the hex encoded hash of the contents of each file
+// in paths keyed by path. The hash function is selected by HashAlgo.
+// The files are hashed as stored, i.e., without being decompressed or
+// having their whitespace trimmed, and are read one at a time without
+// holding their contents in memory.
+func (p *Parts) Checksums() (map[string]string, error) {
+	if _, err := p.newHash(); err != nil {
+		return nil, err
+	}
+	entries, err := p.readdir(context.Background(), 0)
+	if err != nil {
+		return nil, err
+	}
+	sums := make(map[string]string, len(entries))
+	for _, e := range entries {
+		sum, err := p.checksumFile(e)
+		if err != nil {
+			return nil, fmt.Errorf("parts: %w", err)
+		}
+		sums[e.path] = sum
+	}
+
+	return sums, nil
+}
+
+// checksumFile returns the hex encoded hash of the contents of the
+// file described by e.
+func (p *Parts) checksumFile(e entry) (string, error) {
+	h, err := p.newHash()
+	if err != nil {
+		return "", err
+	}
+	file, err := p.openContent(e, false)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(h, file)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Checksum returns the hex encoded hash of the contents of the files
+// in paths exactly as returned by Read and WriteTo, including any
+// separators and headers, e.g., to detect a change to any of the
+// files or to their order. The contents are hashed from the beginning,
+// ignoring any read in progress. The hash function is selected by
+// HashAlgo.
+func (p *Parts) Checksum() (string, error) {
+	h, err := p.newHash()
+	if err != nil {
+		return "", err
+	}
+	if _, err := p.writeAll(h); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
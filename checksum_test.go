@@ -0,0 +1,93 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts_test
+
+import (
+	"crypto"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"testing"
+
+	"github.com/apatters/go-parts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksums(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	sums, err := p.Checksums()
+	t.Logf("sums: %v", sums)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	fileNames, err := p.Readdirnames(0)
+	require.NoError(t, err)
+	require.Len(t, sums, len(fileNames))
+	for _, fileName := range fileNames {
+		content, err := ioutil.ReadFile(fileName)
+		require.NoError(t, err)
+		sum := sha256.Sum256(content)
+		assert.Equal(t, hex.EncodeToString(sum[:]), sums[fileName])
+	}
+
+	config.HashAlgo = crypto.MD5
+	sums, err = p.Checksums()
+	t.Logf("sums: %v", sums)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	content, err := ioutil.ReadFile("testdata/test.conf")
+	require.NoError(t, err)
+	sum := md5.Sum(content)
+	assert.Equal(t, hex.EncodeToString(sum[:]), sums["testdata/test.conf"])
+
+	config.HashAlgo = crypto.BLAKE2b_256
+	sums, err = p.Checksums()
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+	assert.Nil(t, sums)
+}
+
+func TestChecksum(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	config.Separator = []byte("\n---\n")
+
+	p := parts.NewParts(testDataPaths, config)
+	defer p.Close()
+	sum, err := p.Checksum()
+	t.Logf("sum: %s", sum)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(p)
+	require.NoError(t, err)
+	expected := sha256.Sum256(b)
+	assert.Equal(t, hex.EncodeToString(expected[:]), sum)
+
+	again, err := p.Checksum()
+	require.NoError(t, err)
+	assert.Equal(t, sum, again)
+
+	config.Reverse = true
+	reversed, err := p.Checksum()
+	t.Logf("reversed: %s", reversed)
+	require.NoError(t, err)
+	assert.NotEqual(t, sum, reversed)
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// CopyTo copies the file that takes precedence for each base name into
+// destDir with the same base name and permissions, e.g., to render the
+// effective configuration of layered directories into a single one.
+// The files are copied as stored, i.e., without being decompressed or
+// transcoded. Files that are not regular files, e.g., directories
+// included by IncludeDirs, are skipped. Existing files in destDir are
+// overwritten if OverwriteExisting is set, otherwise CopyTo fails when
+// it reaches one. The paths of the files written so far are returned
+// in "run-parts" order, even if there is an error.
+func (p *Parts) CopyTo(destDir string) ([]string, error) {
+	candidates, err := p.candidates(context.Background(), false, nil)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := p.sortEntries(candidates, 0)
+	if err != nil {
+		return nil, err
+	}
+	written := make([]string, 0, len(entries))
+	for _, e := range entries {
+		e, err := p.followEntry(e)
+		if err != nil {
+			return written, fmt.Errorf("parts: %w", err)
+		}
+		if !e.mode.IsRegular() {
+			continue
+		}
+		dest := filepath.Join(destDir, filepath.Base(e.path))
+		if err := p.copyFile(dest, e); err != nil {
+			return written, fmt.Errorf("parts: %w", err)
+		}
+		written = append(written, dest)
+	}
+
+	return written, nil
+}
+
+// copyFile copies the contents of the file described by e to dest and
+// sets the permissions of dest to those of the file.
+func (p *Parts) copyFile(dest string, e entry) error {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !p.Config.OverwriteExisting {
+		flag |= os.O_EXCL
+	}
+	perm := os.FileMode(e.mode.Perm())
+	out, err := os.OpenFile(dest, flag, perm)
+	if err != nil {
+		return err
+	}
+	in, err := p.openContent(e, false)
+	if err != nil {
+		_ = out.Close()
+		return err
+	}
+	_, err = io.Copy(out, in)
+	_ = in.Close()
+	if err == nil {
+		// The permissions of an existing file, or those masked by
+		// the umask, are not set by OpenFile.
+		err = out.Chmod(perm)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
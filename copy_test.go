@@ -0,0 +1,86 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/apatters/go-parts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyTo(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not supported")
+	}
+	src, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(src)
+	override, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(override)
+	dest, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dest)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(src, "10-first"), []byte("first\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(src, "20-second"), []byte("second\n"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(override, "20-second"), []byte("override\n"), 0700))
+	require.NoError(t, os.Mkdir(filepath.Join(src, "30-dir"), 0755))
+
+	config := parts.NewDefaultConfig()
+	config.IncludeDirs = true
+	p := parts.NewParts([]string{override, src}, config)
+	written, err := p.CopyTo(dest)
+	t.Logf("written: %v", written)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dest, "10-first"),
+		filepath.Join(dest, "20-second"),
+	}, written)
+
+	contents, err := ioutil.ReadFile(filepath.Join(dest, "10-first"))
+	require.NoError(t, err)
+	assert.Equal(t, "first\n", string(contents))
+	info, err := os.Stat(filepath.Join(dest, "10-first"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	contents, err = ioutil.ReadFile(filepath.Join(dest, "20-second"))
+	require.NoError(t, err)
+	assert.Equal(t, "override\n", string(contents))
+	info, err = os.Stat(filepath.Join(dest, "20-second"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	_, err = os.Stat(filepath.Join(dest, "30-dir"))
+	assert.True(t, os.IsNotExist(err))
+
+	// Existing files are not overwritten by default.
+	written, err = p.CopyTo(dest)
+	t.Logf("written: %v", written)
+	t.Logf("err: %v", err)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, os.ErrExist))
+	assert.Empty(t, written)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(override, "20-second"), []byte("changed\n"), 0700))
+	config.OverwriteExisting = true
+	written, err = p.CopyTo(dest)
+	t.Logf("written: %v", written)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Len(t, written, 2)
+	contents, err = ioutil.ReadFile(filepath.Join(dest, "20-second"))
+	require.NoError(t, err)
+	assert.Equal(t, "changed\n", string(contents))
+}
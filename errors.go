@@ -0,0 +1,114 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrNoPaths is returned when a Parts has no paths to
+	// traverse.
+	ErrNoPaths = errors.New("parts: no paths configured")
+
+	// ErrPathNotFound is matched by the errors returned when one
+	// of the paths does not exist. The underlying *PathError can
+	// be retrieved using errors.As.
+	ErrPathNotFound = errors.New("parts: path not found")
+
+	// ErrNoMatch is matched by the errors returned when no file
+	// with the requested base name passes the filters, e.g., by
+	// FindFirst and Stat.
+	ErrNoMatch = errors.New("parts: no matching file found")
+)
+
+// pathNotFoundError wraps the error returned when a path does not
+// exist so that it matches both ErrPathNotFound and the underlying
+// error.
+type pathNotFoundError struct {
+	err error
+}
+
+func (e *pathNotFoundError) Error() string {
+	return "parts: " + e.err.Error()
+}
+
+func (e *pathNotFoundError) Unwrap() error {
+	return e.err
+}
+
+func (e *pathNotFoundError) Is(target error) bool {
+	return target == ErrPathNotFound
+}
+
+// multiError combines the errors of several operations that were all
+// attempted, e.g., closing several files. It matches any error matched
+// by one of them.
+type multiError struct {
+	msg  string
+	errs []error
+}
+
+func (e *multiError) Error() string {
+	msgs := make([]string, 0, len(e.errs))
+	for _, err := range e.errs {
+		msgs = append(msgs, err.Error())
+	}
+
+	return fmt.Sprintf("parts: %s: %s", e.msg, strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the combined errors.
+func (e *multiError) Unwrap() []error {
+	return e.errs
+}
+
+func (e *multiError) Is(target error) bool {
+	for _, err := range e.errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *multiError) As(target interface{}) bool {
+	for _, err := range e.errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// joinErrors returns nil if errs is empty, its only error if it has
+// one, and otherwise a *multiError combining all of them, described
+// by msg.
+func joinErrors(msg string, errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+
+	return &multiError{msg: msg, errs: errs}
+}
+
+// pathError returns err, the error retrieving the status of one of
+// the paths, wrapped so that it matches ErrPathNotFound if the path
+// does not exist.
+func pathError(err error) error {
+	if os.IsNotExist(err) {
+		return &pathNotFoundError{err: err}
+	}
+
+	return fmt.Errorf("parts: %w", err)
+}
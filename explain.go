@@ -0,0 +1,98 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts
+
+import (
+	"context"
+	"sort"
+)
+
+// Exclusion describes a file that is not listed by Readdirnames.
+type Exclusion struct {
+	// Path is the path of the file.
+	Path string
+
+	// Reason is the reason the file is excluded, e.g.,
+	// ReasonRegexMiss or ReasonShadowed.
+	Reason FilterReason
+}
+
+// Report describes how paths are traversed. See Parts.Explain.
+type Report struct {
+	// Paths are the paths traversed.
+	Paths []string
+
+	// Files are the paths of the files listed by Readdirnames in
+	// order.
+	Files []string
+
+	// Shadowed holds the paths of the files that pass the filters
+	// but are shadowed by a file with the same base name that
+	// takes precedence, keyed by base name, in order of
+	// precedence. They are also listed in Files if KeepDuplicates
+	// is set.
+	Shadowed map[string][]string
+
+	// Excluded lists the files examined that are not listed by
+	// Readdirnames along with the reasons, including the shadowed
+	// files unless KeepDuplicates is set.
+	Excluded []Exclusion
+
+	// Warnings are the warnings reported by Warnings.
+	Warnings []error
+}
+
+// Explain traverses paths like Readdirnames and reports which files
+// are listed, which are shadowed by files with the same base name, and
+// which are excluded and why, e.g., to find out why a file is not
+// being read. Files in directories not traversed, e.g., because
+// Recursive is not set, are not reported.
+func (p *Parts) Explain() (*Report, error) {
+	var excluded []Exclusion
+	candidates, err := p.candidates(context.Background(), true, &excluded)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{
+		Paths:    append([]string{}, p.Paths...),
+		Shadowed: make(map[string][]string),
+		Excluded: excluded,
+		Warnings: p.Warnings(),
+	}
+	listed := candidates
+	if !p.Config.KeepDuplicates {
+		listed = make(map[string][]entry, len(candidates))
+	}
+	names := make([]string, 0, len(candidates))
+	for name := range candidates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		entries := candidates[name]
+		if !p.Config.KeepDuplicates {
+			listed[name] = entries[:1]
+		}
+		for _, e := range entries[1:] {
+			if err := p.absEntry(&e); err != nil {
+				return nil, err
+			}
+			report.Shadowed[name] = append(report.Shadowed[name], e.path)
+			if !p.Config.KeepDuplicates {
+				report.Excluded = append(report.Excluded, Exclusion{Path: e.path, Reason: ReasonShadowed})
+			}
+		}
+	}
+	entries, err := p.sortEntries(listed, 0)
+	if err != nil {
+		return nil, err
+	}
+	report.Files = make([]string, 0, len(entries))
+	for _, e := range entries {
+		report.Files = append(report.Files, e.path)
+	}
+
+	return report, nil
+}
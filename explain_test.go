@@ -0,0 +1,105 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/apatters/go-parts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	report, err := p.Explain()
+	t.Logf("report: %+v", report)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	fileNames, err := p.Readdirnames(0)
+	require.NoError(t, err)
+	assert.Equal(t, testDataPaths, report.Paths)
+	assert.Equal(t, fileNames, report.Files)
+	assert.Equal(
+		t,
+		map[string][]string{
+			"10-both.conf":    {"testdata/usr/lib/10-both.conf"},
+			"30-symlink.conf": {"testdata/usr/lib/30-symlink.conf"},
+		},
+		report.Shadowed)
+	assert.Contains(t, report.Excluded, parts.Exclusion{
+		Path:   "testdata/usr/lib/40-noconf",
+		Reason: parts.ReasonRegexMiss,
+	})
+	assert.Contains(t, report.Excluded, parts.Exclusion{
+		Path:   "testdata/usr/lib/10-both.conf",
+		Reason: parts.ReasonShadowed,
+	})
+	assert.Empty(t, report.Warnings)
+
+	config.ModeTypeFilter = parts.ModeDir
+	config.KeepDuplicates = true
+	report, err = p.Explain()
+	t.Logf("report: %+v", report)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Empty(t, report.Files)
+	assert.Contains(t, report.Excluded, parts.Exclusion{
+		Path:   "testdata/etc/10-both.conf",
+		Reason: parts.ReasonTypeMiss,
+	})
+	assert.NotContains(t, report.Excluded, parts.Exclusion{
+		Path:   "testdata/usr/lib/10-both.conf",
+		Reason: parts.ReasonShadowed,
+	})
+
+	p = parts.NewParts(nil, config)
+	report, err = p.Explain()
+	t.Logf("err: %v", err)
+	assert.True(t, errors.Is(err, parts.ErrNoPaths))
+	assert.Nil(t, report)
+}
+
+func TestConfigMatch(t *testing.T) {
+	config, err := parts.NewConfigWithFilters(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.ExecutableModePermFilter,
+		[]string{`\.conf$`},
+		[]string{`^20-`})
+	require.NoError(t, err)
+	config.ExcludeHidden = true
+
+	for _, test := range []struct {
+		name     string
+		mode     parts.FileMode
+		expected parts.FilterReason
+	}{
+		{"10-foo.conf", parts.ModeRegular | 0755, parts.ReasonIncluded},
+		{"/etc/foo.d/10-foo.conf", parts.FileMode(os.FileMode(0755)), parts.ReasonIncluded},
+		{"10-foo.txt", parts.ModeRegular | 0755, parts.ReasonRegexMiss},
+		{"20-foo.conf", parts.ModeRegular | 0755, parts.ReasonExcludeMatch},
+		{".10-foo.conf", parts.ModeRegular | 0755, parts.ReasonHidden},
+		{"10-foo.conf", parts.ModeRegular | 0644, parts.ReasonPermMiss},
+		{"10-foo.conf", parts.ModeDir | 0755, parts.ReasonTypeMiss},
+	} {
+		reason := config.Match(test.name, test.mode)
+		t.Logf("name: %s, mode: %s, reason: %s", test.name, test.mode, reason)
+		assert.Equal(t, test.expected, reason)
+	}
+	assert.Equal(t, "does not match the regexp", parts.ReasonRegexMiss.String())
+	assert.Equal(t, "unknown", parts.FilterReason(-1).String())
+}
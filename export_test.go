@@ -0,0 +1,23 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts
+
+import (
+	"io"
+)
+
+// SetOpenFunc replaces the function used by p to open files for
+// reading.
+func SetOpenFunc(p *Parts, open func(name string) (io.ReadCloser, error)) {
+	p.open = open
+}
+
+// ContentCacheLen returns the number of files in the content cache of
+// p.
+func ContentCacheLen(p *Parts) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.cache)
+}
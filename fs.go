@@ -0,0 +1,203 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts
+
+import (
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// lstatFS is implemented by file systems that can describe a symbolic
+// link without following it.
+type lstatFS interface {
+	fs.FS
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+// NewPartsFS is the Parts constructor for traversing the file system
+// fsys instead of the host file system, e.g., an embed.FS. The paths
+// must be valid fs.FS path names. A default configuration is used if
+// config is nil.
+func NewPartsFS(fsys fs.FS, paths []string, config *Config) *Parts {
+	p := NewParts(paths, config)
+	p.fsys = fsys
+
+	return p
+}
+
+// StatModeFS returns the FileMode for the named path in fsys. If
+// there is an error, it will be of type *PathError.
+func StatModeFS(fsys fs.FS, name string) (FileMode, error) {
+	fileInfo, err := fs.Stat(fsys, name)
+	if err != nil {
+		return 0, err
+	}
+
+	return ModeFromFileInfo(fileInfo), nil
+}
+
+// LstatModeFS returns the FileMode for the named path in fsys. If the
+// path is a symbolic link and fsys has an Lstat method, the returned
+// FileMode describes the symbolic link. If there is an error, it will
+// be of type *PathError.
+func LstatModeFS(fsys fs.FS, name string) (FileMode, error) {
+	lfsys, ok := fsys.(lstatFS)
+	if !ok {
+		return StatModeFS(fsys, name)
+	}
+	fileInfo, err := lfsys.Lstat(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return ModeFromFileInfo(fileInfo), nil
+}
+
+// stat returns the FileInfo for the named file. Symbolic links are
+// followed if follow is true.
+func (p *Parts) stat(name string, follow bool) (os.FileInfo, error) {
+	switch {
+	case p.fsys == nil && follow:
+		return os.Stat(name)
+	case p.fsys == nil:
+		return os.Lstat(name)
+	}
+	if lfsys, ok := p.fsys.(lstatFS); ok && !follow {
+		return lfsys.Lstat(name)
+	}
+
+	return fs.Stat(p.fsys, name)
+}
+
+// readDirNamesChunked calls fn with the names of the files in the
+// named directory, at most n at a time. All of the names are passed
+// in a single call if n <= 0 or the directory cannot be read in
+// chunks. If fn returns an error, it is returned.
+func (p *Parts) readDirNamesChunked(name string, n int, fn func(names []string) error) error {
+	if n <= 0 {
+		names, err := p.readDirNames(name)
+		if err != nil {
+			return err
+		}
+		return fn(names)
+	}
+	if p.fsys == nil {
+		dir, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer dir.Close()
+		for {
+			names, err := dir.Readdirnames(n)
+			if len(names) > 0 {
+				if err := fn(names); err != nil {
+					return err
+				}
+			}
+			switch {
+			case err == io.EOF:
+				return nil
+			case err != nil:
+				return err
+			}
+		}
+	}
+	file, err := p.fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	dir, ok := file.(fs.ReadDirFile)
+	if !ok {
+		names, err := p.readDirNames(name)
+		if err != nil {
+			return err
+		}
+		return fn(names)
+	}
+	for {
+		dirEntries, err := dir.ReadDir(n)
+		if len(dirEntries) > 0 {
+			names := make([]string, 0, len(dirEntries))
+			for _, dirEntry := range dirEntries {
+				names = append(names, dirEntry.Name())
+			}
+			if err := fn(names); err != nil {
+				return err
+			}
+		}
+		switch {
+		case err == io.EOF:
+			return nil
+		case err != nil:
+			return err
+		}
+	}
+}
+
+// readDirNames returns the names of the files in the named directory.
+func (p *Parts) readDirNames(name string) ([]string, error) {
+	if p.fsys == nil {
+		dir, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer dir.Close()
+		return dir.Readdirnames(0)
+	}
+	dirEntries, err := fs.ReadDir(p.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		names = append(names, dirEntry.Name())
+	}
+
+	return names, nil
+}
+
+// openFile opens the named file for reading.
+func (p *Parts) openFile(name string) (io.ReadCloser, error) {
+	switch {
+	case p.open != nil:
+		return p.open(name)
+	case p.fsys != nil:
+		return p.fsys.Open(name)
+	default:
+		return os.Open(name)
+	}
+}
+
+// readFile returns the contents of the named file.
+func (p *Parts) readFile(name string) ([]byte, error) {
+	if p.fsys == nil {
+		return ioutil.ReadFile(name)
+	}
+
+	return fs.ReadFile(p.fsys, name)
+}
+
+// join joins a directory and file name into a path.
+func (p *Parts) join(dir string, name string) string {
+	if p.fsys == nil {
+		return filepath.Join(dir, name)
+	}
+
+	return path.Join(dir, name)
+}
+
+// clean returns the shortest path name equivalent to name.
+func (p *Parts) clean(name string) string {
+	if p.fsys == nil {
+		return filepath.Clean(name)
+	}
+
+	return path.Clean(name)
+}
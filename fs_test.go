@@ -0,0 +1,171 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts_test
+
+import (
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/apatters/go-parts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartsFSDirFS(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	expectedFileNames, err := p.Readdirnames(0)
+	require.NoError(t, err)
+	defer p.Close()
+	expectedContents, err := ioutil.ReadAll(p)
+	require.NoError(t, err)
+
+	fsPaths := make([]string, 0, len(testDataPaths))
+	for _, path := range testDataPaths {
+		fsPaths = append(fsPaths, strings.TrimPrefix(path, "testdata/"))
+	}
+	fsp := parts.NewPartsFS(os.DirFS("testdata"), fsPaths, config)
+	fileNames, err := fsp.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	require.Len(t, fileNames, len(expectedFileNames))
+	for i, fileName := range fileNames {
+		assert.Equal(t, expectedFileNames[i], "testdata/"+fileName)
+	}
+
+	defer fsp.Close()
+	contents, err := ioutil.ReadAll(fsp)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, string(expectedContents), string(contents))
+}
+
+func TestPartsFSMapFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"etc/app.d/10-override.conf":     {Data: []byte("etc override\n"), Mode: 0644},
+		"etc/app.d/20-local.conf":        {Data: []byte("etc local\n"), Mode: 0644},
+		"usr/lib/app.d/10-override.conf": {Data: []byte("lib override\n"), Mode: 0644},
+		"usr/lib/app.d/15-default.conf":  {Data: []byte("lib default\n"), Mode: 0644},
+		"usr/lib/app.d/30-script.sh":     {Data: []byte("#!/bin/sh\n"), Mode: 0755},
+	}
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	require.NoError(t, err)
+
+	p := parts.NewPartsFS(fsys, []string{"etc/app.d", "usr/lib/app.d"}, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"etc/app.d/10-override.conf",
+			"usr/lib/app.d/15-default.conf",
+			"etc/app.d/20-local.conf",
+		},
+		fileNames)
+
+	config.ReadDirChunkSize = 1
+	chunkedFileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("chunkedFileNames: %s", chunkedFileNames)
+	require.NoError(t, err)
+	assert.Equal(t, fileNames, chunkedFileNames)
+
+	defer p.Close()
+	contents, err := ioutil.ReadAll(p)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, "etc override\nlib default\netc local\n", string(contents))
+
+	mode, err := parts.StatModeFS(fsys, "usr/lib/app.d/30-script.sh")
+	t.Logf("mode: %s", mode)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.True(t, mode.IsExecutable())
+
+	mode, err = parts.LstatModeFS(fsys, "usr/lib/app.d")
+	t.Logf("mode: %s", mode)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.True(t, mode.IsDir())
+
+	p = parts.NewPartsFS(fsys, []string{"notexist"}, config)
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+	assert.Empty(t, fileNames)
+}
+
+// latencyFS adds a fixed delay to each Stat call on the wrapped file
+// system to simulate a network file system.
+type latencyFS struct {
+	fstest.MapFS
+	latency time.Duration
+}
+
+func (l latencyFS) Stat(name string) (fs.FileInfo, error) {
+	time.Sleep(l.latency)
+	return l.MapFS.Stat(name)
+}
+
+func benchmarkReaddirnamesFS(b *testing.B, concurrency int) {
+	fsys := fstest.MapFS{}
+	for i := 0; i < 100; i++ {
+		fsys[fmt.Sprintf("conf.d/%03d-file.conf", i)] = &fstest.MapFile{Mode: 0644}
+	}
+	config := parts.NewDefaultConfig()
+	config.Concurrency = concurrency
+	p := parts.NewPartsFS(latencyFS{MapFS: fsys, latency: 100 * time.Microsecond}, []string{"conf.d"}, config)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Readdirnames(0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReaddirnamesFS(b *testing.B) {
+	benchmarkReaddirnamesFS(b, 0)
+}
+
+func BenchmarkReaddirnamesFSConcurrency8(b *testing.B) {
+	benchmarkReaddirnamesFS(b, 8)
+}
+
+func BenchmarkReaddirnamesFSCacheStats(b *testing.B) {
+	fsys := fstest.MapFS{}
+	for i := 0; i < 100; i++ {
+		fsys[fmt.Sprintf("conf.d/%03d-file.conf", i)] = &fstest.MapFile{Mode: 0644}
+	}
+	config := parts.NewDefaultConfig()
+	config.CacheStats = true
+	p := parts.NewPartsFS(latencyFS{MapFS: fsys, latency: 100 * time.Microsecond}, []string{"conf.d"}, config)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Readdirnames(0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts
+
+import (
+	"regexp"
+	"strings"
+)
+
+// LSBRejectedSuffixes lists the file name suffixes of package manager
+// backup and save files that are never valid LSB names.
+var LSBRejectedSuffixes = []string{
+	".dpkg-old",
+	".dpkg-dist",
+	".dpkg-new",
+	".dpkg-tmp",
+	".rpmsave",
+	".rpmnew",
+	".rpmorig",
+}
+
+// lsbNameRegExps are the name spaces accepted by run-parts
+// --lsbsysinit: LANANA-assigned names, LSB hierarchical and reserved
+// names, and Debian cron script names.
+var lsbNameRegExps = []*regexp.Regexp{
+	regexp.MustCompile(`^[a-z0-9]+$`),
+	regexp.MustCompile(`^_?([a-z0-9_.]+-)+[a-z0-9]+$`),
+	regexp.MustCompile(`^[a-zA-Z0-9_-]+$`),
+}
+
+// IsLSBName reports whether name would be run by "run-parts
+// --lsbsysinit". The name must be made up of ASCII letters, digits,
+// underscores, hyphens, and dots following one of the LANANA, LSB
+// hierarchical, or Debian cron name spaces, and must not end in one of
+// the LSBRejectedSuffixes.
+func IsLSBName(name string) bool {
+	for _, suffix := range LSBRejectedSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return false
+		}
+	}
+	for _, regExp := range lsbNameRegExps {
+		if regExp.MatchString(name) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apatters/go-parts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	lsbNameData = []struct {
+		Name  string
+		Valid bool
+	}{
+		{"apache2", true},
+		{"50-local", true},
+		{"Local_Script", true},
+		{"_lsb-reserved.d-name", true},
+		{"debian.cron-daily", true},
+		{"10-both.conf", false},
+		{"name with spaces", false},
+		{"backup~", false},
+		{"50-local.dpkg-old", false},
+		{"50-local.dpkg-dist", false},
+		{"50-local.dpkg-new", false},
+		{"50-local.dpkg-tmp", false},
+		{"50-local.rpmsave", false},
+		{"50-local.rpmnew", false},
+		{"50-local.rpmorig", false},
+	}
+)
+
+func TestIsLSBName(t *testing.T) {
+	for _, datum := range lsbNameData {
+		t.Logf("name: %q, valid: %t", datum.Name, datum.Valid)
+		assert.Equal(t, datum.Valid, parts.IsLSBName(datum.Name))
+	}
+}
+
+func TestWalkLSBNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, datum := range lsbNameData {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, datum.Name), nil, 0644))
+	}
+
+	config := parts.NewDefaultConfig()
+	config.LSBNames = true
+	p := parts.NewParts([]string{dir}, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			filepath.Join(dir, "50-local"),
+			filepath.Join(dir, "Local_Script"),
+			filepath.Join(dir, "_lsb-reserved.d-name"),
+			filepath.Join(dir, "apache2"),
+			filepath.Join(dir, "debian.cron-daily"),
+		},
+		fileNames)
+}
@@ -0,0 +1,114 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	markerStartPrefix = "\x00PARTS:"
+	markerStartSuffix = "\x00"
+	markerEnd         = "\x00/PARTS\x00"
+)
+
+// markerReader reads the contents of the parts directory with each
+// file wrapped in start and end markers.
+type markerReader struct {
+	p      *Parts
+	files  []io.ReadCloser
+	reader io.Reader
+	err    error
+}
+
+// ReadWithMarkers returns a reader over the contents of the parts
+// directory in which each file is preceded by a
+// "\x00PARTS:<path>\x00" marker and followed by a "\x00/PARTS\x00"
+// marker. Any configured Separator is not written. Errors resolving
+// or opening the files are returned by the first call to Read. The
+// output can be split back into files using ParseMarkers.
+func (p *Parts) ReadWithMarkers() io.ReadCloser {
+	return &markerReader{p: p}
+}
+
+func (m *markerReader) Read(b []byte) (int, error) {
+	if m.reader == nil && m.err == nil {
+		m.err = m.open()
+	}
+	if m.err != nil {
+		return 0, m.err
+	}
+
+	return m.reader.Read(b)
+}
+
+// open resolves and opens the files in the parts directory.
+func (m *markerReader) open() error {
+	entries, err := m.p.readdir(context.Background(), 0)
+	if err != nil {
+		return err
+	}
+	useCache := m.p.useCache(entries)
+	readers := make([]io.Reader, 0, 3*len(entries))
+	for _, e := range entries {
+		file, err := m.p.openEntry(e, useCache)
+		if err != nil {
+			return err
+		}
+		m.files = append(m.files, file)
+		readers = append(
+			readers,
+			bytes.NewReader([]byte(markerStartPrefix+e.path+markerStartSuffix)),
+			file,
+			bytes.NewReader([]byte(markerEnd)))
+	}
+	m.reader = io.MultiReader(readers...)
+
+	return nil
+}
+
+// Close closes all files opened by Read.
+func (m *markerReader) Close() error {
+	err := closeAll(m.files)
+	m.files = nil
+
+	return err
+}
+
+// ParseMarkers reads the output of ReadWithMarkers from r and returns
+// the contents of each file keyed by its path. File contents
+// containing NUL bytes cannot be parsed reliably.
+func ParseMarkers(r io.Reader) (map[string][]byte, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	contents := make(map[string][]byte)
+	for len(b) > 0 {
+		if !bytes.HasPrefix(b, []byte(markerStartPrefix)) {
+			return nil, errors.New("parts: missing start marker")
+		}
+		b = b[len(markerStartPrefix):]
+		i := bytes.Index(b, []byte(markerStartSuffix))
+		if i < 0 {
+			return nil, errors.New("parts: unterminated start marker")
+		}
+		path := string(b[:i])
+		b = b[i+len(markerStartSuffix):]
+		i = bytes.Index(b, []byte(markerEnd))
+		if i < 0 {
+			return nil, fmt.Errorf("parts: %s: missing end marker", path)
+		}
+		contents[path] = b[:i]
+		b = b[i+len(markerEnd):]
+	}
+
+	return contents, nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/apatters/go-parts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkersRoundTrip(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+
+	r := p.ReadWithMarkers()
+	defer r.Close()
+	contents, err := parts.ParseMarkers(r)
+	t.Logf("err: %v", err)
+	t.Logf("contents: %q", contents)
+	require.NoError(t, err)
+	require.Len(t, contents, len(fileNames))
+	for _, fileName := range fileNames {
+		expected, err := ioutil.ReadFile(fileName)
+		require.NoError(t, err)
+		assert.Equal(t, string(expected), string(contents[fileName]))
+	}
+}
+
+func TestMarkersErrors(t *testing.T) {
+	p := parts.NewParts([]string{"/notexist"}, nil)
+	r := p.ReadWithMarkers()
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+	assert.Empty(t, b)
+
+	for _, s := range []string{
+		"garbage",
+		"\x00PARTS:noend",
+		"\x00PARTS:path\x00contents",
+	} {
+		_, err = parts.ParseMarkers(strings.NewReader(s))
+		t.Logf("s: %q", s)
+		t.Logf("err: %v", err)
+		assert.Error(t, err)
+	}
+}
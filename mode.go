@@ -5,7 +5,10 @@
 package parts
 
 import (
+	"fmt"
 	"os"
+	"strings"
+	"unicode/utf8"
 )
 
 // FileMode is an extension of the os.FileMode struct. It adds a
@@ -29,15 +32,28 @@ const (
 	ModeSticky     = FileMode(os.ModeSticky)     // t: sticky
 	ModeRegular    = 1 << (31 - iota)            // f: regular file
 
+	// ModeIrregular marks a file of an unknown type. It takes the
+	// place of os.ModeIrregular, which shares its bit with
+	// ModeRegular, in modes converted by ModeFromFileInfo.
+	ModeIrregular FileMode = 1 << 18 // ?: irregular file
+
 	// Mask for the type bits.
-	ModeType = ModeDir | ModeSymlink | ModeNamedPipe | ModeSocket | ModeDevice | ModeRegular
+	ModeType = ModeDir | ModeSymlink | ModeNamedPipe | ModeSocket | ModeDevice | ModeRegular | ModeIrregular
 
 	// Unix permission bits
 	ModePerm FileMode = 0777
 )
 
+// modeTypeLetters are the abbreviations of the mode bits used by the
+// String method, ordered from the most significant bit.
+const modeTypeLetters = "dalTLDpSugctf?"
+
+// modePermLetters are the abbreviations of the permission bits used
+// by the String method, ordered from the most significant bit.
+const modePermLetters = "rwxrwxrwx"
+
 func (m FileMode) String() string {
-	const str = "dalTLDpSugctf"
+	const str = modeTypeLetters
 	var buf [32]byte // Mode is uint32.
 	w := 0
 	for i, c := range str {
@@ -50,7 +66,7 @@ func (m FileMode) String() string {
 		buf[w] = '-'
 		w++
 	}
-	const rwx = "rwxrwxrwx"
+	const rwx = modePermLetters
 	for i, c := range rwx {
 		if m&(1<<uint(9-1-i)) != 0 {
 			buf[w] = byte(c)
@@ -62,46 +78,210 @@ func (m FileMode) String() string {
 	return string(buf[:w])
 }
 
+// ParseMode parses a string in the format produced by
+// FileMode.String, e.g., "frwxr-xr-x", and returns the corresponding
+// FileMode. The string must consist of ASCII characters only.
+func ParseMode(s string) (FileMode, error) {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return 0, fmt.Errorf("parts: invalid mode %q: not ASCII", s)
+		}
+	}
+	if len(s) <= len(modePermLetters) {
+		return 0, fmt.Errorf("parts: invalid mode %q: too short", s)
+	}
+	typeStr := s[:len(s)-len(modePermLetters)]
+	permStr := s[len(s)-len(modePermLetters):]
+
+	var m FileMode
+	if typeStr != "-" {
+		next := 0
+		for j := 0; j < len(typeStr); j++ {
+			c := typeStr[j]
+			i := strings.IndexByte(modeTypeLetters[next:], c)
+			if i < 0 {
+				return 0, fmt.Errorf("parts: invalid mode %q: unexpected type letter %q", s, c)
+			}
+			next += i
+			m |= 1 << uint(32-1-next)
+			next++
+		}
+	}
+	for i := 0; i < len(permStr); i++ {
+		c := permStr[i]
+		switch c {
+		case modePermLetters[i]:
+			m |= 1 << uint(9-1-i)
+		case '-':
+		default:
+			return 0, fmt.Errorf("parts: invalid mode %q: unexpected permission letter %q", s, c)
+		}
+	}
+
+	return m, nil
+}
+
 // IsDir reports whether m describes a directory.
 func (m FileMode) IsDir() bool {
 	return m&ModeDir != 0
 }
 
-// IsRegular reports whether m describes a regular file.
+// IsRegular reports whether m describes a regular file, i.e.,
+// ModeRegular is set or none of the os.FileMode type bits nor
+// ModeIrregular are set, so that modes converted from an os.FileMode
+// are classified the same way as by os.FileMode.IsRegular. Since
+// ModeRegular shares its bit with os.ModeIrregular, modes of
+// irregular files must be converted by ModeFromFileInfo.
 func (m FileMode) IsRegular() bool {
 	switch {
 	case m&ModeRegular != 0:
 		return true
 	// Maintain conversion compatibility with os.FileMode.
-	case m&ModeType == 0:
+	case m&(FileMode(os.ModeType)|ModeIrregular) == 0:
 		return true
 	default:
 		return false
 	}
 }
 
+// Equal reports whether m and other describe the same mode, treating
+// a mode with ModeRegular set and the same mode converted from an
+// os.FileMode without it as equal, e.g., ModeRegular|0644 equals
+// FileMode(os.FileMode(0644)).
+func (m FileMode) Equal(other FileMode) bool {
+	return m.normalize() == other.normalize()
+}
+
+// normalize returns m with ModeRegular set if it describes a regular
+// file.
+func (m FileMode) normalize() FileMode {
+	if m.IsRegular() {
+		return m | ModeRegular
+	}
+
+	return m
+}
+
+// IsSymlink reports whether m describes a symbolic link.
+func (m FileMode) IsSymlink() bool {
+	return m&ModeSymlink != 0
+}
+
+// IsSocket reports whether m describes a Unix domain socket.
+func (m FileMode) IsSocket() bool {
+	return m&ModeSocket != 0
+}
+
+// IsNamedPipe reports whether m describes a named pipe (FIFO).
+func (m FileMode) IsNamedPipe() bool {
+	return m&ModeNamedPipe != 0
+}
+
+// IsDevice reports whether m describes a device file.
+func (m FileMode) IsDevice() bool {
+	return m&ModeDevice != 0
+}
+
+// IsCharDevice reports whether m describes a Unix character device.
+func (m FileMode) IsCharDevice() bool {
+	return m&ModeDevice != 0 && m&ModeCharDevice != 0
+}
+
 // Perm returns the permission bits of the file mode.
 func (m FileMode) Perm() FileMode {
 	return m & ModePerm
 }
 
+// Type returns the type bits of the file mode, including
+// ModeCharDevice so that character and block devices have different
+// types. The ModeRegular bit is set for regular files even if m was
+// converted from an os.FileMode, and ModeIrregular is returned for
+// irregular files.
+func (m FileMode) Type() FileMode {
+	t := m & (ModeType | ModeCharDevice)
+	if t == 0 {
+		return ModeRegular
+	}
+
+	return t
+}
+
+// IsValid reports whether m is a coherent combination of mode bits,
+// i.e., at most one of the type bits in ModeType is set and
+// ModeCharDevice is only set along with ModeDevice.
+func (m FileMode) IsValid() bool {
+	t := m & ModeType
+	if t&(t-1) != 0 {
+		return false
+	}
+	if m&ModeCharDevice != 0 && m&ModeDevice == 0 {
+		return false
+	}
+
+	return true
+}
+
 // IsExecutable reports whether m describes an executablexs file.
 func (m FileMode) IsExecutable() bool {
 	return m.IsRegular() && (m&0111 != 0)
 }
 
+// IsSetuid reports whether m has the setuid bit set.
+func (m FileMode) IsSetuid() bool {
+	return m&ModeSetuid != 0
+}
+
+// IsSetgid reports whether m has the setgid bit set.
+func (m FileMode) IsSetgid() bool {
+	return m&ModeSetgid != 0
+}
+
 // FileInfo extends the os.FileInfo struct.
 type FileInfo struct {
 	os.FileInfo
+	mode FileMode
 }
 
 // Mode returns the file mode bits of the file with an adjustment made
-// for regular files.
+// for regular files as described by ModeFromFileInfo.
 func (i FileInfo) Mode() FileMode {
-	m := i.FileInfo.Mode()
-	if m&os.ModeType == 0 {
-		return ModeRegular
+	if i.mode != 0 {
+		return i.mode
+	}
+
+	return ModeFromFileInfo(i.FileInfo)
+}
+
+// IsDir reports whether i describes a directory. It overrides the
+// method of the embedded os.FileInfo with the same result.
+func (i FileInfo) IsDir() bool {
+	return i.Mode().IsDir()
+}
+
+// IsRegular reports whether i describes a regular file.
+func (i FileInfo) IsRegular() bool {
+	return i.Mode().IsRegular()
+}
+
+// IsExecutable reports whether i describes an executable file.
+func (i FileInfo) IsExecutable() bool {
+	return i.Mode().IsExecutable()
+}
+
+// ModeFromFileInfo converts the os.FileMode in fileInfo to a
+// FileMode, setting the ModeRegular bit for regular files and
+// replacing os.ModeIrregular with ModeIrregular so that irregular
+// files are not mistaken for regular files. It can be used with an
+// os.FileInfo obtained elsewhere, e.g., from filepath.Walk, to get the
+// same FileMode as StatMode without retrieving the status again.
+func ModeFromFileInfo(fileInfo os.FileInfo) FileMode {
+	mode := fileInfo.Mode()
+	switch {
+	case mode.IsRegular():
+		return FileMode(mode) | ModeRegular
+	case mode&os.ModeIrregular != 0:
+		return FileMode(mode&^os.ModeIrregular) | ModeIrregular
 	}
 
-	return FileMode(m)
+	return FileMode(mode)
 }
@@ -2,10 +2,14 @@ package parts_test
 
 import (
 	"fmt"
+	"io/fs"
+	"os"
 	"testing"
+	"testing/fstest"
 
 	"github.com/apatters/go-parts"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -26,7 +30,7 @@ var (
 		{parts.ModeRegular | 0666, "frw-rw-rw-", regularTypeStr},
 		{parts.ModeDir | 0775, "drwxrwxr-x", dirTypeStr},
 		{parts.ModeRegular | 0775, "frwxrwxr-x", execTypeStr},
-		{parts.ModeType | parts.ModePerm, "dLDpSfrwxrwxrwx", allTypeStr},
+		{parts.ModeType | parts.ModePerm, "dLDpSf?rwxrwxrwx", allTypeStr},
 	}
 )
 
@@ -62,3 +66,277 @@ func TestModePerm(t *testing.T) {
 		assert.EqualValues(t, "-"+datum.StringRepr[len(datum.StringRepr)-9:], perm.String())
 	}
 }
+
+func TestModeTypePredicates(t *testing.T) {
+	data := []struct {
+		Mode         parts.FileMode
+		IsSymlink    bool
+		IsSocket     bool
+		IsNamedPipe  bool
+		IsDevice     bool
+		IsCharDevice bool
+	}{
+		{parts.ModeRegular | 0644, false, false, false, false, false},
+		{parts.ModeSymlink | 0777, true, false, false, false, false},
+		{parts.ModeSocket | 0755, false, true, false, false, false},
+		{parts.ModeNamedPipe | 0644, false, false, true, false, false},
+		{parts.ModeDevice | 0660, false, false, false, true, false},
+		{parts.ModeDevice | parts.ModeCharDevice | 0660, false, false, false, true, true},
+	}
+	for _, datum := range data {
+		t.Logf("Mode: %s", datum.Mode)
+		assert.Equal(t, datum.IsSymlink, datum.Mode.IsSymlink())
+		assert.Equal(t, datum.IsSocket, datum.Mode.IsSocket())
+		assert.Equal(t, datum.IsNamedPipe, datum.Mode.IsNamedPipe())
+		assert.Equal(t, datum.IsDevice, datum.Mode.IsDevice())
+		assert.Equal(t, datum.IsCharDevice, datum.Mode.IsCharDevice())
+	}
+}
+
+func TestModeType(t *testing.T) {
+	for _, datum := range modeData {
+		t.Logf("Mode: %s", datum.Mode)
+		modeType := datum.Mode.Type()
+		t.Logf("type: = %s", modeType)
+		assert.Zero(t, modeType.Perm())
+		assert.Equal(t, datum.Mode&parts.ModeType, modeType)
+	}
+
+	// Character and block devices have different types.
+	charDevice := parts.FileMode(os.ModeDevice | os.ModeCharDevice | 0660)
+	blockDevice := parts.FileMode(os.ModeDevice | 0660)
+	t.Logf("char: %s, block: %s", charDevice.Type(), blockDevice.Type())
+	assert.Equal(t, parts.ModeDevice|parts.ModeCharDevice, charDevice.Type())
+	assert.Equal(t, parts.ModeDevice, blockDevice.Type())
+	assert.NotEqual(t, charDevice.Type(), blockDevice.Type())
+	assert.Equal(t,
+		os.FileMode(os.ModeDevice|os.ModeCharDevice).Type() == os.FileMode(os.ModeDevice).Type(),
+		charDevice.Type() == blockDevice.Type())
+
+	m := parts.FileMode(os.FileMode(0644))
+	t.Logf("Mode: %s", m)
+	assert.Equal(t, parts.FileMode(parts.ModeRegular), m.Type())
+	assert.Equal(t, parts.FileMode(parts.ModeRegular|0755).Type(), m.Type())
+	assert.NotEqual(t, (parts.ModeDir | 0755).Type(), m.Type())
+}
+
+func TestParseMode(t *testing.T) {
+	modes := []parts.FileMode{
+		0,
+		0644,
+		parts.ModeSetuid | parts.ModeRegular | 0755,
+		parts.ModeDevice | parts.ModeCharDevice | 0660,
+	}
+	for _, datum := range modeData {
+		modes = append(modes, datum.Mode)
+	}
+	for _, m := range modes {
+		parsed, err := parts.ParseMode(m.String())
+		t.Logf("Mode: %s, parsed: %s", m, parsed)
+		t.Logf("err: %v", err)
+		require.NoError(t, err)
+		assert.Equal(t, m, parsed)
+	}
+
+	for _, s := range []string{
+		"",
+		"rwxrwxrwx",
+		"frwxrwxrw",
+		"xrwxrwxrwx",
+		"fdrwxrwxrwx",
+		"ffrwxrwxrwx",
+		"frwxrwxrwr",
+		"f-rwxrwxrwx",
+		"f\u0172xrwxrwx",
+		"\u0172rwxrwxrwx",
+		"frwxrwxrw\u00e9",
+	} {
+		m, err := parts.ParseMode(s)
+		t.Logf("s: %q, mode: %s", s, m)
+		t.Logf("err: %v", err)
+		assert.Error(t, err)
+	}
+}
+
+func TestModeIsValid(t *testing.T) {
+	tests := []struct {
+		Mode  parts.FileMode
+		Valid bool
+	}{
+		{0, true},
+		{0644, true},
+		{parts.FileMode(parts.ModeRegular | 0755), true},
+		{parts.ModeDir | parts.ModeSetgid | 0755, true},
+		{parts.ModeSymlink | 0777, true},
+		{parts.ModeDevice | 0660, true},
+		{parts.ModeDevice | parts.ModeCharDevice | 0660, true},
+		{parts.ModeNamedPipe | parts.ModeSticky | 0600, true},
+		{parts.ModeIrregular | 0644, true},
+		{parts.FileMode(parts.ModeDir | parts.ModeRegular | 0755), false},
+		{parts.ModeSymlink | parts.ModeDir | 0777, false},
+		{parts.ModeSocket | parts.ModeNamedPipe, false},
+		{parts.ModeCharDevice | 0660, false},
+		{parts.FileMode(parts.ModeRegular | parts.ModeCharDevice), false},
+		{parts.ModeDir | parts.ModeIrregular | 0755, false},
+		{parts.FileMode(parts.ModeRegular | parts.ModeIrregular | 0644), false},
+	}
+	for _, test := range tests {
+		t.Logf("Mode: %s, valid: %t", test.Mode, test.Valid)
+		assert.Equal(t, test.Valid, test.Mode.IsValid())
+	}
+}
+
+func TestModeIsRegularConverted(t *testing.T) {
+	tests := []struct {
+		Mode    os.FileMode
+		Regular bool
+	}{
+		{0644, true},
+		{os.ModeSetuid | 0755, true},
+		{os.ModeDir | 0755, false},
+		{os.ModeSymlink | 0777, false},
+		{os.ModeSocket | 0755, false},
+		{os.ModeNamedPipe | 0644, false},
+		{os.ModeDevice | 0660, false},
+		{os.ModeDevice | os.ModeCharDevice | 0660, false},
+		{os.ModeCharDevice | 0660, false},
+	}
+	for _, test := range tests {
+		m := parts.FileMode(test.Mode)
+		t.Logf("Mode: %s, regular: %t", m, test.Regular)
+		assert.Equal(t, test.Mode.IsRegular(), test.Regular)
+		assert.Equal(t, test.Regular, m.IsRegular())
+		assert.False(t, m.IsExecutable() && !test.Regular)
+	}
+}
+
+func TestModeFromFileInfo(t *testing.T) {
+	for _, name := range []string{
+		"testdata/etc/10-both.conf",
+		"testdata/etc/30-symlink.conf",
+		"testdata/usr/lib/10-executable.sh",
+		"testdata/usr/lib",
+	} {
+		info, err := os.Lstat(name)
+		require.NoError(t, err)
+		m := parts.ModeFromFileInfo(info)
+		t.Logf("name: %s, mode: %s", name, m)
+		lstatMode, err := parts.LstatMode(name)
+		require.NoError(t, err)
+		assert.Equal(t, lstatMode, m)
+		assert.Equal(t, info.Mode().IsRegular(), m.IsRegular())
+		assert.Equal(t, parts.FileMode(info.Mode().Perm()), m.Perm())
+
+		info, err = os.Stat(name)
+		require.NoError(t, err)
+		statMode, err := parts.StatMode(name)
+		require.NoError(t, err)
+		assert.Equal(t, statMode, parts.ModeFromFileInfo(info))
+	}
+}
+
+func TestModeFromFileInfoIrregular(t *testing.T) {
+	fsys := fstest.MapFS{
+		"irregular": &fstest.MapFile{Mode: os.ModeIrregular | 0644},
+	}
+	info, err := fs.Stat(fsys, "irregular")
+	require.NoError(t, err)
+	m := parts.ModeFromFileInfo(info)
+	t.Logf("mode: %s", m)
+	assert.False(t, m.IsRegular())
+	assert.False(t, m.IsExecutable())
+	assert.Equal(t, parts.ModeIrregular, m.Type())
+	assert.Equal(t, "?rw-r--r--", m.String())
+	assert.Equal(t, parts.FileMode(0644), m.Perm())
+	parsed, err := parts.ParseMode(m.String())
+	require.NoError(t, err)
+	assert.Equal(t, m, parsed)
+
+	// Irregular files do not pass the default type filter but can
+	// be selected explicitly.
+	config := parts.NewDefaultConfig()
+	assert.Equal(t, parts.ReasonTypeMiss, config.Match("10-irregular", m))
+	config, err = parts.NewConfigOptions(
+		parts.WithModeType(parts.ModeIrregular),
+		parts.WithModePerm(parts.ModePerm))
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, parts.ReasonIncluded, config.Match("10-irregular", m))
+	assert.Equal(t, parts.ReasonTypeMiss, config.Match("10-regular", parts.ModeRegular|0644))
+}
+
+func TestFileInfoPredicates(t *testing.T) {
+	config := parts.NewDefaultConfig()
+	config.IncludeDirs = true
+	p := parts.NewParts([]string{"testdata/usr/lib"}, config)
+	infos, err := p.Readdir(0)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	require.NotEmpty(t, infos)
+	for _, info := range infos {
+		t.Logf("name: %s, mode: %s", info.Name(), info.Mode())
+		assert.Equal(t, info.Mode().IsDir(), info.IsDir())
+		assert.Equal(t, info.FileInfo.IsDir(), info.IsDir())
+		assert.Equal(t, info.Mode().IsRegular(), info.IsRegular())
+		assert.Equal(t, info.Mode().IsExecutable(), info.IsExecutable())
+		switch info.Name() {
+		case "adir":
+			assert.True(t, info.IsDir())
+			assert.False(t, info.IsRegular())
+			assert.False(t, info.IsExecutable())
+		case "10-executable.sh":
+			assert.False(t, info.IsDir())
+			assert.True(t, info.IsRegular())
+			assert.True(t, info.IsExecutable())
+		case "10-both.conf":
+			assert.False(t, info.IsDir())
+			assert.True(t, info.IsRegular())
+			assert.False(t, info.IsExecutable())
+		}
+	}
+}
+
+func TestModeEqual(t *testing.T) {
+	for _, test := range []struct {
+		a, b     parts.FileMode
+		expected bool
+	}{
+		{parts.ModeRegular | 0644, parts.FileMode(os.FileMode(0644)), true},
+		{parts.FileMode(os.FileMode(0644)), parts.ModeRegular | 0644, true},
+		{parts.ModeRegular | 0644, parts.ModeRegular | 0644, true},
+		{parts.FileMode(os.FileMode(0644)), parts.FileMode(os.FileMode(0644)), true},
+		{parts.ModeRegular | 0644, parts.FileMode(os.FileMode(0755)), false},
+		{parts.ModeRegular | 0755, parts.ModeDir | 0755, false},
+		{parts.FileMode(os.ModeDir | 0755), parts.ModeDir | 0755, true},
+		{parts.FileMode(os.ModeSymlink | 0777), parts.ModeRegular | 0777, false},
+		{parts.FileMode(os.ModeSymlink | 0777), parts.ModeSymlink | 0777, true},
+	} {
+		t.Logf("a: %s, b: %s", test.a, test.b)
+		assert.Equal(t, test.expected, test.a.Equal(test.b))
+	}
+
+	info, err := os.Stat("testdata/etc/10-both.conf")
+	require.NoError(t, err)
+	statMode, err := parts.StatMode("testdata/etc/10-both.conf")
+	require.NoError(t, err)
+	assert.True(t, statMode.Equal(parts.FileMode(info.Mode())))
+	assert.NotEqual(t, statMode, parts.FileMode(info.Mode()))
+}
+
+func TestModeSetuid(t *testing.T) {
+	for _, test := range []struct {
+		mode           parts.FileMode
+		setuid, setgid bool
+	}{
+		{parts.ModeRegular | 0755, false, false},
+		{parts.ModeRegular | parts.ModeSetuid | 0755, true, false},
+		{parts.ModeRegular | parts.ModeSetgid | 0755, false, true},
+		{parts.ModeRegular | parts.ModeSetuid | parts.ModeSetgid | 0755, true, true},
+		{parts.FileMode(os.ModeSetuid | 04755), true, false},
+		{parts.ModeDir | parts.ModeSetgid | 0755, false, true},
+	} {
+		t.Logf("mode: %s", test.mode)
+		assert.Equal(t, test.setuid, test.mode.IsSetuid())
+		assert.Equal(t, test.setgid, test.mode.IsSetgid())
+	}
+}
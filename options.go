@@ -0,0 +1,80 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Option configures a Config. It is passed to NewConfigOptions.
+type Option func(*Config) error
+
+// NewConfigOptions constructor. The default configuration is modified
+// by each of opts in turn. Can fail if an option fails, e.g., a
+// regular expression does not compile.
+func NewConfigOptions(opts ...Option) (*Config, error) {
+	config := NewDefaultConfig()
+	for _, opt := range opts {
+		if err := opt(config); err != nil {
+			return nil, err
+		}
+	}
+
+	return config, nil
+}
+
+// WithReverse sets whether files are sorted in reverse order.
+func WithReverse(reverse bool) Option {
+	return func(c *Config) error {
+		c.Reverse = reverse
+		return nil
+	}
+}
+
+// WithModeType sets the file type filter. Fails if it contains bits
+// other than the type bits in ModeType.
+func WithModeType(modeTypeFilter FileMode) Option {
+	return func(c *Config) error {
+		if err := validateModeTypeFilter(modeTypeFilter); err != nil {
+			return err
+		}
+		c.ModeTypeFilter = modeTypeFilter
+		return nil
+	}
+}
+
+// WithModePerm sets the file permission filter. Fails if it contains
+// bits other than the permission bits in ModePerm.
+func WithModePerm(modePermFilter FileMode) Option {
+	return func(c *Config) error {
+		if err := validateModePermFilter(modePermFilter); err != nil {
+			return err
+		}
+		c.ModePermFilter = modePermFilter
+		return nil
+	}
+}
+
+// WithRegExp sets the file name regular expression filter.
+func WithRegExp(regExpFilter string) Option {
+	return func(c *Config) error {
+		regExp, err := regexp.Compile(regExpFilter)
+		if err != nil {
+			return fmt.Errorf("parts: %w", err)
+		}
+		c.RegExpFilter = regExp
+		return nil
+	}
+}
+
+// WithGlob sets the file name shell pattern filter. Fails if the
+// pattern is malformed.
+func WithGlob(globFilter string) Option {
+	return func(c *Config) error {
+		c.GlobFilter = globFilter
+		return c.validateGlob()
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts_test
+
+import (
+	"testing"
+
+	"github.com/apatters/go-parts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfigOptions(t *testing.T) {
+	config, err := parts.NewConfigOptions()
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, parts.NewDefaultConfig(), config)
+
+	config, err = parts.NewConfigOptions(
+		parts.WithReverse(true),
+		parts.WithModeType(parts.ExecutableModeTypeFilter),
+		parts.WithModePerm(parts.ExecutableModePermFilter),
+		parts.WithRegExp(`\.sh$`))
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	expected, err := parts.NewConfig(
+		true,
+		parts.ExecutableModeTypeFilter,
+		parts.ExecutableModePermFilter,
+		`\.sh$`)
+	require.NoError(t, err)
+	assert.Equal(t, expected, config)
+
+	config, err = parts.NewConfigOptions(parts.WithRegExp(`(`))
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+	assert.Nil(t, config)
+}
+
+func TestConfigString(t *testing.T) {
+	config := parts.NewDefaultConfig()
+	t.Logf("config: %v", config)
+	assert.Equal(t, `Config{reverse=false, type=f, perm=0777, regexp=".*"}`, config.String())
+
+	config, err := parts.NewConfig(
+		true,
+		parts.ModeDir|parts.ModeSymlink,
+		parts.ExecutableModePermFilter,
+		`\.conf$`)
+	require.NoError(t, err)
+	t.Logf("config: %v", config)
+	assert.Equal(t, `Config{reverse=true, type=dL, perm=0111, regexp="\\.conf$"}`, config.String())
+}
+
+func TestWithGlob(t *testing.T) {
+	config, err := parts.NewConfigOptions(parts.WithGlob("*.conf"))
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, "*.conf", config.GlobFilter)
+
+	config, err = parts.NewConfigOptions(parts.WithGlob("[-"))
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+	assert.Nil(t, config)
+}
+
+func TestConfigModeFilterValidation(t *testing.T) {
+	// The filters are swapped.
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModePermFilter,
+		parts.DefaultModeTypeFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+	assert.Nil(t, config)
+
+	config, err = parts.NewConfigWithFilters(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModeTypeFilter,
+		nil,
+		nil)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+	assert.Nil(t, config)
+
+	config, err = parts.NewConfigOptions(parts.WithModeType(parts.ModePerm))
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+	assert.Nil(t, config)
+
+	config, err = parts.NewConfigOptions(parts.WithModePerm(parts.ModeDir))
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+	assert.Nil(t, config)
+
+	config, err = parts.NewConfigOptions(
+		parts.WithModeType(parts.ModeType),
+		parts.WithModePerm(parts.ModePerm))
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, parts.ModeType, config.ModeTypeFilter)
+	assert.Equal(t, parts.ModePerm, config.ModePermFilter)
+}
@@ -0,0 +1,21 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+//go:build windows || plan9
+// +build windows plan9
+
+package parts
+
+import (
+	"os"
+)
+
+// ownershipSupported is true if files have Unix uid/gid ownership.
+const ownershipSupported = false
+
+// fileOwner returns the uid and gid of the file described by
+// info. Ownership is never available on this platform.
+func fileOwner(info os.FileInfo) (int, int, bool) {
+	return 0, 0, false
+}
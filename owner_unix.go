@@ -0,0 +1,28 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package parts
+
+import (
+	"os"
+	"syscall"
+)
+
+// ownershipSupported is true if files have Unix uid/gid ownership.
+const ownershipSupported = true
+
+// fileOwner returns the uid and gid of the file described by
+// info. It returns false if the ownership is not available, e.g., the
+// file is not from the operating system's file system.
+func fileOwner(info os.FileInfo) (int, int, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+
+	return int(stat.Uid), int(stat.Gid), true
+}
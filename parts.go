@@ -33,7 +33,9 @@ precedence. e.g., using the following directory layouts:
             └── nodigits.conf
 
 The etc/10-both.conf file is used and the /usr/lib/10-both.conf is
-ignored if etc is configured ahead of /usr/lib.
+ignored if etc is configured ahead of /usr/lib. The precedence can be
+inverted with Config.LastWins so that later directories take
+precedence instead.
 
 After duplicates are resolved, the files are lexically sorted
 (optionally in reverse order).
@@ -57,13 +59,20 @@ parts.Read().
 package parts
 
 import (
+	"bytes"
+	"context"
+	"crypto"
 	"fmt"
 	"io"
+	"io/fs"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -75,19 +84,344 @@ const (
 )
 
 // Config is used to pass parameter to NewConfig.
+//
+// A file name is kept if it matches none of the ExcludeRegExps and
+// matches at least one of the IncludeRegExps or RegExpFilter. A nil
+// RegExpFilter and empty IncludeRegExps matches all names.
 type Config struct {
 	Reverse        bool
 	ModeTypeFilter FileMode
 	ModePermFilter FileMode
 	RegExpFilter   *regexp.Regexp
+	IncludeRegExps []*regexp.Regexp
+	ExcludeRegExps []*regexp.Regexp
+
+	// RegExpMatchesFullPath matches RegExpFilter against the full
+	// path of files in directories, e.g., "testdata/etc/10-foo",
+	// instead of their base names, e.g., to only include files
+	// under an "enabled" subdirectory with `/enabled/`. The other
+	// name filters, including IncludeRegExps and ExcludeRegExps,
+	// still match base names.
+	RegExpMatchesFullPath bool
+
+	// GlobFilter only includes files in directories whose names
+	// match the shell pattern as described by filepath.Match,
+	// e.g., "*.conf". It is checked in addition to the regular
+	// expressions, so a name must pass both. The empty string
+	// matches all names.
+	GlobFilter string
+
+	// Extensions only includes files in directories whose
+	// extensions, as returned by filepath.Ext, are in the list,
+	// e.g., []string{".conf", ".yaml"}. The leading "." may be
+	// omitted. Like GlobFilter, it is checked in addition to the
+	// regular expressions. An empty list matches all names.
+	Extensions []string
+
+	// KeepDuplicates disables de-duplication so that files with
+	// the same base name from all of the paths are listed and
+	// read, e.g., to audit layered configurations. Files with the
+	// same base name are listed next to each other in order of
+	// precedence. Lookup, Stat, and FindFirst still return the
+	// file that takes precedence.
+	KeepDuplicates bool
+
+	// LastWins gives files from directories appearing later in
+	// paths precedence over files with the same base name from
+	// directories appearing earlier, e.g., for systemd style
+	// drop-ins where /etc is listed after /usr/lib. It only changes
+	// which duplicate is kept, not the order of the results.
+	LastWins bool
+
+	// IncludeDirs includes directories found in paths in addition
+	// to the file types selected by ModeTypeFilter, i.e., a
+	// directory passes the type check even if ModeDir is not set
+	// in ModeTypeFilter. Directories must still pass the other
+	// filters, e.g., the name regexps and ModePermFilter.
+	IncludeDirs bool
+
+	// Recursive traverses the subdirectories of directories in
+	// paths. Files from all levels are filtered and de-duplicated
+	// by base name together, with files closer to the top of a
+	// path taking precedence over deeper ones. MaxDepth limits how
+	// many levels of subdirectories are traversed; zero means
+	// there is no limit. Symbolic links to directories are not
+	// traversed.
+	Recursive bool
+	MaxDepth  int
+
+	// ExcludeHidden excludes files in directories whose names
+	// begin with a ".", e.g., editor swap files.
+	ExcludeHidden bool
+
+	// LSBNames only includes files in directories whose names are
+	// valid according to the rules used by "run-parts
+	// --lsbsysinit". See IsLSBName.
+	LSBNames bool
+
+	// DisabledSuffix excludes files in directories whose names end
+	// with the suffix, e.g., ".disabled", as well as files that
+	// have a sibling marker file named after them with the suffix
+	// appended, e.g., "10-foo.disabled" disables "10-foo". The
+	// empty string disables the check.
+	DisabledSuffix string
+
+	// ModifiedAfter and ModifiedBefore only include files whose
+	// modification time is strictly after and strictly before the
+	// given times respectively. The zero time disables the check.
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+
+	// MinSize and MaxSize only include files whose size in bytes
+	// is at least MinSize and at most MaxSize. A MaxSize of zero
+	// means there is no upper bound.
+	MinSize int64
+	MaxSize int64
+
+	// OwnerUID only includes files owned by the given user id if
+	// FilterOwner is set, and OwnerGID only includes files owned
+	// by the given group id if FilterGroup is set. Files whose
+	// ownership cannot be determined, e.g., files in an fs.FS, are
+	// excluded. Ownership filtering is not supported on platforms
+	// without Unix ownership semantics, e.g., Windows, and results
+	// in an error there.
+	OwnerUID    int
+	OwnerGID    int
+	FilterOwner bool
+	FilterGroup bool
+
+	// RejectWorldWritable excludes files that are writable by
+	// others, i.e., have the 0002 permission bit set, even if they
+	// match all of the other filters. The excluded files are
+	// reported by Parts.Warnings.
+	RejectWorldWritable bool
+
+	// RejectSetuid excludes files that have the setuid or setgid
+	// bit set, even if they match all of the other filters, e.g.,
+	// to ensure that no privileged file is executed by Run. The
+	// excluded files are reported by Parts.Warnings.
+	RejectSetuid bool
+
+	// NoFollowSymlinks determines the mode of files found in
+	// directories without following symbolic links, i.e., the
+	// mode of the link itself is used so that ModeSymlink in
+	// ModeTypeFilter matches symbolic links. By default symbolic
+	// links are followed.
+	NoFollowSymlinks bool
+
+	// RegularOrSymlinkToRegular only includes regular files and
+	// symbolic links that resolve to regular files, e.g., to keep
+	// symbolic links to configuration files kept elsewhere while
+	// excluding symbolic links to directories and broken ones. It
+	// is checked in addition to ModeTypeFilter, which must include
+	// ModeSymlink for symbolic links to be included when
+	// NoFollowSymlinks is set.
+	RegularOrSymlinkToRegular bool
+
+	// SkipBrokenSymlinks skips symbolic links in directories whose
+	// targets do not exist instead of returning an error. The
+	// skipped links are reported by Parts.Warnings.
+	SkipBrokenSymlinks bool
+
+	// ContinueOnError skips paths, directories, and files that
+	// cannot be read instead of returning an error so that the
+	// files from the remaining paths are still listed. The errors
+	// are reported by Parts.Warnings.
+	ContinueOnError bool
+
+	// ExitOnError stops Parts.Run at the first file that fails,
+	// i.e., exits with a non-zero exit code or cannot be run,
+	// instead of running the remaining files, like "run-parts
+	// --exit-on-error" does.
+	ExitOnError bool
+
+	// PerFileTimeout limits how long each file run by Parts.Run may
+	// run. A process that runs for longer is killed with SIGKILL,
+	// without a grace period, and its RunResult records an error
+	// matching context.DeadlineExceeded. The remaining files are
+	// still run unless ExitOnError is set. Processes started by the
+	// file are killed along with it on platforms with process
+	// groups, e.g., Linux. Zero means there is no limit.
+	PerFileTimeout time.Duration
+
+	// Umask sets the file mode creation mask of the processes
+	// started by Parts.Run, e.g., 022, like the --umask option of
+	// run-parts, if SetUmask is set. Otherwise the processes
+	// inherit the mask of the current process. The mask is set by
+	// /bin/sh in the new process before it executes the file, so
+	// the mask of the current process is never changed. It is not
+	// supported on platforms without Unix permissions, e.g.,
+	// Windows, and results in an error there.
+	Umask    int
+	SetUmask bool
+
+	// IgnoreMissingDirs treats paths that do not exist as if they
+	// were empty directories, e.g., an optional /etc/foo.d
+	// overriding /usr/lib/foo.d. Other errors are still returned.
+	IgnoreMissingDirs bool
+
+	// Collator compares two file base names returning a negative
+	// number, zero, or a positive number when a sorts before, the
+	// same as, or after b. It can be used for locale-aware
+	// ordering, e.g., with golang.org/x/text/collate. The names are
+	// compared bytewise if it is nil.
+	Collator func(a, b string) int
+
+	// NumericSort compares the leading decimal number of file base
+	// names numerically, e.g., "20-foo" sorts before "100-bar". The
+	// remainder of the names is compared when the numbers are
+	// equal. Names without a leading number sort after names with
+	// one.
+	NumericSort bool
+
+	// CaseInsensitiveSort compares file base names after
+	// converting them to lower case, e.g., "apache.conf" sorts
+	// before "Zope.conf". Names that differ only in case are
+	// compared as is. It is applied before NumericSort.
+	CaseInsensitiveSort bool
+
+	// SortByFullPath compares the full paths of files instead of
+	// their base names when sorting, e.g., to group the files from
+	// each directory together when base names collide
+	// intentionally. This departs from the order used by run-parts.
+	// The other sorting options, e.g., Reverse and Collator, are
+	// still applied. It is ignored if LessFunc is set.
+	SortByFullPath bool
+
+	// SortBy selects the key files are sorted by. The default,
+	// SortByName, sorts by name as described above. SortByModTime
+	// and SortBySize sort the oldest and the smallest files first
+	// respectively, with ties ordered by name. Reverse is applied
+	// on top of the key, e.g., to list the most recently modified
+	// files first. It is ignored if LessFunc is set.
+	SortBy SortKey
+
+	// AbsolutePaths converts the paths of the files found to
+	// absolute paths using filepath.Abs, e.g., so that they can be
+	// passed to a process running in a different working
+	// directory. It is ignored by Parts traversing an fs.FS.
+	AbsolutePaths bool
+
+	// OverwriteExisting allows CopyTo to overwrite files that
+	// already exist in the destination directory. Otherwise CopyTo
+	// fails when it reaches one.
+	OverwriteExisting bool
+
+	// RequireSignature only includes files that have a sibling
+	// signature file, i.e., the file name with a ".sig" suffix,
+	// for which Verify returns true. Signature files themselves
+	// are never included. Files without a valid signature are
+	// skipped unless FailOnBadSignature is set in which case an
+	// error is returned. The contents of each file are held in
+	// memory once verified, and exactly the verified contents are
+	// read, e.g., by Read, WriteTo, and Open, so a file replaced
+	// after it is selected is never read unverified.
+	RequireSignature   bool
+	Verify             func(content, sig []byte) bool
+	FailOnBadSignature bool
+
+	// LessFunc reports whether the file with path a sorts before
+	// the file with path b. It replaces the base name comparison,
+	// including Collator and NumericSort, if it is set. Files for
+	// which neither path is less than the other, e.g., files with
+	// the same modification time when sorting by it, are ordered
+	// by base name. Reverse is still applied.
+	LessFunc func(a, b string) bool
+
+	// Separator is written between the contents of each file by
+	// Read and WriteTo. It is not written after the last file.
+	Separator []byte
+
+	// FileHeaderFunc returns a header that is written immediately
+	// before the contents of the file with the given path by Read
+	// and WriteTo, e.g., []byte("# " + path + "\n"). It is written
+	// after any Separator.
+	FileHeaderFunc func(path string) []byte
+
+	// OnFileStart is called by Read with the path of a file just
+	// before the first bytes belonging to it, including any
+	// Separator and header preceding it, are returned, e.g., to
+	// correlate the bytes read with their files. It is not called
+	// for files that contribute no bytes. It must not call the
+	// methods of the Parts reading it.
+	OnFileStart func(path string)
+
+	// HashAlgo selects the hash function used by Parts.Checksums,
+	// e.g., crypto.SHA1. The zero value selects crypto.SHA256.
+	// crypto.MD5 and crypto.SHA1 are always available; other hash
+	// functions require importing the package implementing them.
+	HashAlgo crypto.Hash
+
+	// TrimTrailingWhitespace strips trailing spaces and tabs from
+	// each line of each file read by Read and WriteTo.
+	TrimTrailingWhitespace bool
+
+	// EnsureTrailingNewline ensures that the contents of each file
+	// read by Read and WriteTo end in exactly one line ending,
+	// adding a "\n" if the file does not end in one and removing
+	// any additional trailing blank lines, so that the contents of
+	// the next file always start on a new line. Unlike Separator,
+	// nothing is added to a file that already ends in a line
+	// ending. Empty files are read unchanged.
+	EnsureTrailingNewline bool
+
+	// Decompress decompresses the contents of files whose names
+	// end in ".gz" read by Read and WriteTo. Other files are read
+	// unchanged.
+	Decompress bool
+
+	// Charset transcodes the contents of each file read by Read
+	// and WriteTo from the given character set to UTF-8, e.g.,
+	// "utf-16le" for files written by Windows tools. The supported
+	// character sets are "utf-8", "utf-16le", "utf-16be", "utf-16",
+	// which detects the byte order from a byte order mark, and
+	// "iso-8859-1". A leading UTF-16 byte order mark is removed.
+	// The empty string passes the contents through unchanged.
+	Charset string
+
+	// Concurrency is the maximum number of files in a directory
+	// whose status is retrieved at once, which can speed up
+	// traversals on high-latency file systems. The files are still
+	// filtered and de-duplicated in order, so the results are the
+	// same as for a sequential traversal. Values less than two
+	// disable concurrency.
+	Concurrency int
+
+	// CacheStats caches the names of the files in each directory
+	// so that repeated traversals do not read directories that
+	// have not changed. The cached names for a directory are
+	// discarded when its modification time changes, i.e., when
+	// files are added, removed, or renamed. The status of each
+	// file is still retrieved on every traversal, so changes made
+	// to a file in place, e.g., to its contents or permissions,
+	// are seen.
+	CacheStats bool
+
+	// ReadDirChunkSize is the maximum number of file names read
+	// from a directory at once. Each chunk is filtered before the
+	// next is read, so memory use stays bounded for directories
+	// with very many files. The results are the same as when the
+	// whole directory is read at once, which is done if it is zero
+	// or CacheStats is set.
+	ReadDirChunkSize int
+
+	// ContentCacheBytes is the maximum total size of the files
+	// whose contents are cached in memory by Read and
+	// WriteTo. Caching is disabled if it is zero or the total
+	// size of the files exceeds it.
+	ContentCacheBytes int64
 }
 
 // NewConfig constructor. Can fail if regular expressions do not
-// compile.
+// compile or if the mode filters contain bits of the wrong kind, e.g.,
+// when they are passed in the wrong order.
 func NewConfig(reverse bool, modeTypeFilter FileMode, modePermFilter FileMode, regExpFilter string) (*Config, error) {
+	if err := validateModeFilters(modeTypeFilter, modePermFilter); err != nil {
+		return nil, err
+	}
 	regExp, err := regexp.Compile(regExpFilter)
 	if err != nil {
-		return nil, fmt.Errorf("parts: %s", err)
+		return nil, fmt.Errorf("parts: %w", err)
 	}
 	return &Config{
 		Reverse:        reverse,
@@ -97,6 +431,138 @@ func NewConfig(reverse bool, modeTypeFilter FileMode, modePermFilter FileMode, r
 	}, nil
 }
 
+// NewConfigWithFilters constructor. It is similar to NewConfig but
+// accepts lists of include and exclude regular expressions instead of
+// a single regular expression. Can fail if regular expressions do not
+// compile or if the mode filters contain bits of the wrong kind.
+func NewConfigWithFilters(reverse bool, modeTypeFilter FileMode, modePermFilter FileMode, includeRegExps []string, excludeRegExps []string) (*Config, error) {
+	if err := validateModeFilters(modeTypeFilter, modePermFilter); err != nil {
+		return nil, err
+	}
+	includes, err := compileRegExps(includeRegExps)
+	if err != nil {
+		return nil, err
+	}
+	excludes, err := compileRegExps(excludeRegExps)
+	if err != nil {
+		return nil, err
+	}
+	return &Config{
+		Reverse:        reverse,
+		ModeTypeFilter: modeTypeFilter,
+		ModePermFilter: modePermFilter,
+		IncludeRegExps: includes,
+		ExcludeRegExps: excludes,
+	}, nil
+}
+
+// validateModeFilters returns an error if modeTypeFilter contains bits
+// other than the type bits in ModeType or modePermFilter contains bits
+// other than the permission bits in ModePerm.
+func validateModeFilters(modeTypeFilter FileMode, modePermFilter FileMode) error {
+	if err := validateModeTypeFilter(modeTypeFilter); err != nil {
+		return err
+	}
+
+	return validateModePermFilter(modePermFilter)
+}
+
+// validateModeTypeFilter returns an error if modeTypeFilter contains
+// bits other than the type bits in ModeType.
+func validateModeTypeFilter(modeTypeFilter FileMode) error {
+	if modeTypeFilter&^ModeType != 0 {
+		return fmt.Errorf("parts: invalid type filter %s: contains bits outside of ModeType", modeTypeFilter)
+	}
+
+	return nil
+}
+
+// validateModePermFilter returns an error if modePermFilter contains
+// bits other than the permission bits in ModePerm.
+func validateModePermFilter(modePermFilter FileMode) error {
+	if modePermFilter&^ModePerm != 0 {
+		return fmt.Errorf("parts: invalid permission filter %s: contains bits outside of ModePerm", modePermFilter)
+	}
+
+	return nil
+}
+
+// compileRegExps compiles each of the regular expressions in exprs.
+func compileRegExps(exprs []string) ([]*regexp.Regexp, error) {
+	regExps := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		regExp, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("parts: %w", err)
+		}
+		regExps = append(regExps, regExp)
+	}
+
+	return regExps, nil
+}
+
+// validateGlob returns an error if GlobFilter is not a valid shell
+// pattern.
+func (c *Config) validateGlob() error {
+	if c.GlobFilter == "" {
+		return nil
+	}
+	if _, err := filepath.Match(c.GlobFilter, ""); err != nil {
+		return fmt.Errorf("parts: invalid glob %q: %w", c.GlobFilter, err)
+	}
+
+	return nil
+}
+
+// validateOwner returns an error if ownership filtering is enabled
+// on a platform that does not support it.
+func (c *Config) validateOwner() error {
+	if ownershipSupported || (!c.FilterOwner && !c.FilterGroup) {
+		return nil
+	}
+
+	return fmt.Errorf("parts: file ownership filtering is not supported on this platform")
+}
+
+// String returns a human readable representation of the main
+// filtering parameters in c, e.g.,
+//
+//	Config{reverse=false, type=f, perm=0777, regexp=".*"}
+func (c *Config) String() string {
+	// Strip the "rwxrwxrwx" permission section.
+	typeStr := c.ModeTypeFilter.String()
+	typeStr = typeStr[:len(typeStr)-9]
+	regExpStr := ""
+	if c.RegExpFilter != nil {
+		regExpStr = c.RegExpFilter.String()
+	}
+
+	return fmt.Sprintf(
+		"Config{reverse=%t, type=%s, perm=%04o, regexp=%q}",
+		c.Reverse,
+		typeStr,
+		uint32(c.ModePermFilter),
+		regExpStr)
+}
+
+// Clone returns a copy of c that can be modified without affecting
+// c. The slice fields, i.e., IncludeRegExps, ExcludeRegExps,
+// Extensions, and Separator, are copied. The compiled regular
+// expressions and the functions, e.g., Verify and LessFunc, are shared
+// since they are not modified by Parts.
+func (c *Config) Clone() *Config {
+	if c == nil {
+		return nil
+	}
+	clone := *c
+	clone.IncludeRegExps = append([]*regexp.Regexp(nil), c.IncludeRegExps...)
+	clone.ExcludeRegExps = append([]*regexp.Regexp(nil), c.ExcludeRegExps...)
+	clone.Extensions = append([]string(nil), c.Extensions...)
+	clone.Separator = append([]byte(nil), c.Separator...)
+
+	return &clone
+}
+
 // NewDefaultConfig returns a default Config constructor.
 func NewDefaultConfig() *Config {
 	return &Config{
@@ -109,10 +575,21 @@ func NewDefaultConfig() *Config {
 
 // readState tracks the current state of reading the parts
 // directory. It is mostly used to close directory files during Read()
-// operations.
+// operations. It is also returned by Open as an independent reader.
 type readState struct {
 	Files  []io.ReadCloser
-	Reader io.Reader
+	Paths  []string
+	Reader *fileReader
+}
+
+func (r *readState) Read(b []byte) (int, error) {
+	return r.Reader.Read(b)
+}
+
+// Close closes all of the files being read. See closeAll for the
+// error returned.
+func (r *readState) Close() error {
+	return closeAll(r.Files)
 }
 
 // Parts encapsulates data and functions used to process "run-parts"
@@ -120,7 +597,25 @@ type readState struct {
 type Parts struct {
 	Paths     []string
 	Config    *Config
+	readMu    sync.Mutex // Guards readState.
 	readState *readState
+	mu        sync.Mutex // Guards cache, statCache, and warnings.
+	cache     contentCache
+	statCache statCache
+	warnings  []error
+	open      func(name string) (io.ReadCloser, error)
+	fsys      fs.FS
+
+	// pathConfigs holds the per-path configurations set by
+	// NewPartsMulti indexed like Paths.
+	pathConfigs []*Config
+}
+
+// PathConfig pairs a path with the configuration used to filter the
+// files found in it. See NewPartsMulti.
+type PathConfig struct {
+	Path   string
+	Config *Config
 }
 
 // NewParts is the Parts constructor. A default configuration is used
@@ -136,181 +631,1527 @@ func NewParts(paths []string, config *Config) *Parts {
 	}
 }
 
-// Readdirnames returns a list of files in paths that follow the
-// "run-parts" naming convention.
-func (p *Parts) Readdirnames(n int) ([]string, error) {
-	foundNames := make(map[string]string)
-	for _, path := range p.Paths {
-		mode, err := StatMode(path)
-		if err != nil {
-			return []string{}, fmt.Errorf("parts: %s", err)
-		}
-		switch {
-		case mode.IsDir():
-			dir, err := os.Open(path)
-			if err != nil {
-				return []string{}, fmt.Errorf("parts: %s", err)
-			}
-			defer dir.Close()
-			fileNames, err := dir.Readdirnames(0)
-			if err != nil {
-				return []string{}, fmt.Errorf("parts: %s", err)
-			}
-			for _, fileName := range fileNames {
-				fullPath := filepath.Join(path, fileName)
-				mode, err = StatMode(fullPath)
-				if err != nil {
-					return []string{}, fmt.Errorf("parts: %s", err)
-				}
-				if _, ok := foundNames[fileName]; ok {
-					continue
-				}
-				if p.filter(fileName, mode, p.Config.RegExpFilter) {
-					foundNames[fileName] = fullPath
-				}
-			}
-		default:
-			if _, ok := foundNames[filepath.Base(path)]; ok {
-				continue
-			}
-			if p.filter(filepath.Base(path), mode, nil) {
-				foundNames[filepath.Base(path)] = path
-			}
-		}
+// NewPartsMulti constructor. It is similar to NewParts but each path
+// has its own configuration used to filter the files found in it,
+// e.g., the name regexps, mode filters, and Recursive. Files from all
+// of the paths are still de-duplicated and sorted together. The
+// ordering, reading, and error handling settings, as well as the
+// filters for entries with a nil Config, come from the Config field
+// of the returned Parts, which is set to the default configuration
+// and can be replaced by the caller.
+func NewPartsMulti(entries []PathConfig) *Parts {
+	paths := make([]string, 0, len(entries))
+	configs := make([]*Config, 0, len(entries))
+	for _, pathConfig := range entries {
+		paths = append(paths, pathConfig.Path)
+		configs = append(configs, pathConfig.Config)
 	}
-	names := make([]string, 0, len(foundNames))
-	for _, val := range foundNames {
-		names = append(names, val)
+	p := NewParts(paths, nil)
+	p.pathConfigs = configs
+
+	return p
+}
+
+// Merge returns a Parts that traverses the paths of a followed by the
+// paths of b as if they had all been configured together, so files
+// from a take precedence over files with the same base name from b
+// unless LastWins is set. The returned Parts uses config, or the
+// Config of a if config is nil. Per-path configurations set by
+// NewPartsMulti are kept. The file system of a is used for all of
+// the paths.
+func Merge(a, b *Parts, config *Config) *Parts {
+	if config == nil {
+		config = a.Config
 	}
-	if p.Config.Reverse {
-		sort.Sort(sort.Reverse(pathsByBasename(names)))
-	} else {
-		sort.Sort(pathsByBasename(names))
+	paths := make([]string, 0, len(a.Paths)+len(b.Paths))
+	paths = append(paths, a.Paths...)
+	paths = append(paths, b.Paths...)
+	p := NewParts(paths, config)
+	p.open = a.open
+	p.fsys = a.fsys
+	if len(a.pathConfigs) > 0 || len(b.pathConfigs) > 0 {
+		p.pathConfigs = make([]*Config, len(paths))
+		copy(p.pathConfigs, a.pathConfigs)
+		copy(p.pathConfigs[len(a.Paths):], b.pathConfigs)
 	}
 
-	switch {
-	case n == 0:
-		return names, nil
-	case n < len(names):
-		return names[0:n], nil
-	default:
-		return names, nil
+	return p
+}
+
+// pathConfig returns the configuration used to filter the files
+// found in the i'th path.
+func (p *Parts) pathConfig(i int) *Config {
+	if i < len(p.pathConfigs) && p.pathConfigs[i] != nil {
+		return p.pathConfigs[i]
 	}
+
+	return p.Config
 }
 
-// Read reads the contents of the parts directory into buffer b.
-func (p *Parts) Read(b []byte) (int, error) {
-	if p.readState == nil {
-		// Initialize
-		foundFiles, err := p.Readdirnames(0)
-		if err != nil {
-			return 0, err
+// recursive reports whether Recursive is set for any of the paths.
+func (p *Parts) recursive() bool {
+	for i := range p.Paths {
+		if p.pathConfig(i).Recursive {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pathIndexes returns the indexes of paths in order of precedence.
+func (p *Parts) pathIndexes() []int {
+	indexes := make([]int, 0, len(p.Paths))
+	for i := range p.Paths {
+		if p.Config.LastWins {
+			i = len(p.Paths) - 1 - i
 		}
-		// Create a reader for each file and stuff it away.
-		p.readState = new(readState)
-		p.readState.Files = make([]io.ReadCloser, 0, len(foundFiles))
-		for _, fileName := range foundFiles {
-			file, err := os.Open(fileName)
+		indexes = append(indexes, i)
+	}
+
+	return indexes
+}
+
+// StandardPaths returns the conventional run-parts directories for
+// name, i.e., /etc/<name> followed by /usr/lib/<name>, so that files
+// in /etc override those in /usr/lib. The result can be passed
+// directly to NewParts.
+func StandardPaths(name string) []string {
+	return []string{
+		filepath.Join("/etc", name),
+		filepath.Join("/usr/lib", name),
+	}
+}
+
+// Glob returns the files in paths whose base names match the shell
+// pattern in run-parts order, e.g., Glob(StandardPaths("foo.d"),
+// "*.conf"). The pattern is matched as described by filepath.Match.
+// The default configuration is used otherwise, so files from earlier
+// paths still take precedence over files with the same base name
+// from later paths, and files given directly in paths are included
+// whether or not they match. Fails if the pattern is malformed.
+func Glob(paths []string, pattern string) ([]string, error) {
+	config, err := NewConfigOptions(WithGlob(pattern))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewParts(paths, config).Readdirnames(0)
+}
+
+// ReadDirs returns the concatenated contents of the files in paths
+// selected by config, as returned by Parts.ReadAll, e.g.,
+// ReadDirs(StandardPaths("foo.d"), nil). The default configuration is
+// used if config is nil. All of the files are closed before returning.
+func ReadDirs(paths []string, config *Config) ([]byte, error) {
+	return NewParts(paths, config).ReadAll()
+}
+
+// ExpandPaths returns paths with environment variables and a leading
+// "~" expanded so that the result can be passed to NewParts. Variables
+// of the form $VAR and ${VAR} are expanded by os.ExpandEnv, so unset
+// variables expand to the empty string. A path of "~" or beginning
+// with "~/" has the "~" replaced by the current user's home
+// directory; other forms, e.g., "~user", are left unchanged. Fails if
+// the home directory is needed but cannot be determined.
+func ExpandPaths(paths []string) ([]string, error) {
+	expanded := make([]string, 0, len(paths))
+	for _, path := range paths {
+		path = os.ExpandEnv(path)
+		if path == "~" || strings.HasPrefix(path, "~/") {
+			home, err := os.UserHomeDir()
 			if err != nil {
-				return 0, err
+				return nil, fmt.Errorf("parts: %w", err)
 			}
-			p.readState.Files = append(p.readState.Files, file)
-		}
-		readers := make([]io.Reader, 0, len(p.readState.Files))
-		for _, reader := range p.readState.Files {
-			readers = append(readers, reader)
+			path = home + path[1:]
 		}
-		p.readState.Reader = io.MultiReader(readers...)
+		expanded = append(expanded, path)
 	}
 
-	bytesRead, err := p.readState.Reader.Read(b)
-
-	return bytesRead, err
+	return expanded, nil
 }
 
-// Close closes all files opened by Read.
-func (p *Parts) Close() error {
-	var err error
-	if p.readState == nil {
-		return nil
+// Validate checks that paths can be traversed without listing the
+// contents of any directories, e.g., to fail fast before entering a
+// long running loop. It returns an error if a path cannot be stat'ed
+// or if it is neither a directory nor a regular file. Paths that do
+// not exist are ignored if IgnoreMissingDirs is set. Validate stops at
+// the first invalid path unless ContinueOnError is set, in which case
+// the error combines all of them, e.g., it matches ErrPathNotFound if
+// any of the paths does not exist.
+func (p *Parts) Validate() error {
+	if len(p.Paths) == 0 {
+		return ErrNoPaths
 	}
-	for _, reader := range p.readState.Files {
-		if reader == nil {
+	var errs []error
+	for i, path := range p.Paths {
+		c := p.pathConfig(i)
+		if err := c.validateGlob(); err != nil {
+			return err
+		}
+		if err := c.validateOwner(); err != nil {
+			return err
+		}
+		err := p.validatePath(path)
+		if err == nil {
 			continue
 		}
-		tmpErr := reader.Close()
-		if tmpErr != nil && err != nil {
-			err = tmpErr
+		if !p.Config.ContinueOnError {
+			return err
 		}
+		errs = append(errs, err)
 	}
-	p.readState = nil
 
-	return err
+	return joinErrors(fmt.Sprintf("%d paths are invalid", len(errs)), errs)
 }
 
-// filter returns true if the file name and mode matches the filtering
-// criteria (name regexp, perms, and mode).
-func (p *Parts) filter(name string, mode FileMode, regExp *regexp.Regexp) bool {
-	if regExp != nil && !regExp.MatchString(name) {
-		return false
+// validatePath returns an error if path cannot be traversed.
+func (p *Parts) validatePath(path string) error {
+	e, err := p.statEntry(path, true)
+	if err != nil {
+		if p.Config.IgnoreMissingDirs && os.IsNotExist(err) {
+			return nil
+		}
+		return pathError(err)
 	}
-	if mode&p.Config.ModePermFilter == 0 {
-		return false
+	if !e.mode.IsDir() && !e.mode.IsRegular() {
+		return fmt.Errorf("parts: %s: not a directory or regular file", path)
 	}
-	if mode&p.Config.ModeTypeFilter == 0 {
-		return false
+
+	return nil
+}
+
+// Readdirnames returns a list of files in paths that follow the
+// "run-parts" naming convention. The returned paths are cleaned as by
+// filepath.Clean, e.g., trailing slashes and ".." elements in paths
+// are removed.
+func (p *Parts) Readdirnames(n int) ([]string, error) {
+	return p.ReaddirnamesContext(context.Background(), n)
+}
+
+// ReaddirnamesContext is like Readdirnames but returns ctx.Err() if
+// ctx is canceled or its deadline expires during the traversal. The
+// context is checked before each path and each file is examined.
+func (p *Parts) ReaddirnamesContext(ctx context.Context, n int) ([]string, error) {
+	entries, err := p.readdir(ctx, n)
+	if err != nil {
+		return []string{}, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.path)
 	}
 
-	return true
+	return names, nil
 }
 
-// StatMode returns the FileMode for the named path. If there is an error,
-// it will be of type *PathError.
-func StatMode(name string) (FileMode, error) {
-	fileInfo, err := os.Stat(name)
+// ReaddirnamesRange is like Readdirnames but returns at most limit
+// files starting at offset in the sorted list, e.g., to page through
+// the files. Reverse is applied before the range is selected. The range
+// is clamped to the list, i.e., an offset past the end returns an
+// empty list and a limit <= 0 returns all of the files from offset.
+func (p *Parts) ReaddirnamesRange(offset, limit int) ([]string, error) {
+	names, err := p.Readdirnames(0)
 	if err != nil {
-		return 0, err
+		return names, err
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(names) {
+		offset = len(names)
+	}
+	names = names[offset:]
+	if limit > 0 && limit < len(names) {
+		names = names[:limit]
 	}
 
-	var mode FileMode
-	if fileInfo.Mode().IsRegular() {
-		mode = FileMode(fileInfo.Mode()) | ModeRegular
-	} else {
-		mode = FileMode(fileInfo.Mode())
+	return names, nil
+}
+
+// Basenames is like Readdirnames but returns the base names of the
+// files instead of their paths.
+func (p *Parts) Basenames(n int) ([]string, error) {
+	entries, err := p.readdir(context.Background(), n)
+	if err != nil {
+		return []string{}, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, filepath.Base(e.path))
 	}
 
-	return mode, nil
+	return names, nil
 }
 
-// LstatMode returns the FileMode for the named path. If the path is a
-// symbolic link, the returned FileMode describes the symbolic
-// link. If there is an error, it will be of type *PathError.
-func LstatMode(name string) (FileMode, error) {
-	fileInfo, err := os.Lstat(name)
+// Readdir returns a list of FileInfo for the files in paths that
+// follow the "run-parts" naming convention. It applies the same
+// precedence, filtering, and ordering rules as Readdirnames.
+func (p *Parts) Readdir(n int) ([]FileInfo, error) {
+	entries, err := p.readdir(context.Background(), n)
+	if err != nil {
+		return []FileInfo{}, err
+	}
+	infos := make([]FileInfo, 0, len(entries))
+	for _, e := range entries {
+		infos = append(infos, FileInfo{FileInfo: e.info, mode: e.mode})
+	}
+
+	return infos, nil
+}
+
+// Count returns the number of files in paths that follow the
+// "run-parts" naming convention. It applies the same precedence and
+// filtering rules as Readdirnames but does not sort the files.
+func (p *Parts) Count() (int, error) {
+	candidates, err := p.candidates(context.Background(), p.Config.KeepDuplicates, nil)
 	if err != nil {
 		return 0, err
 	}
+	count := 0
+	for _, entries := range candidates {
+		count += len(entries)
+	}
 
-	var mode FileMode
-	if fileInfo.Mode().IsRegular() {
-		mode = FileMode(fileInfo.Mode()) | ModeRegular
-	} else {
-		mode = FileMode(fileInfo.Mode())
+	return count, nil
+}
+
+// Len returns the total number of bytes that Read and WriteTo
+// produce, i.e., the sum of the sizes of the files in paths plus a
+// Separator between each of them and any file headers. The files are
+// not read. Fails if TrimTrailingWhitespace, EnsureTrailingNewline,
+// Decompress, or Charset is set since the length cannot be known
+// without reading the files.
+func (p *Parts) Len() (int64, error) {
+	if p.Config.TrimTrailingWhitespace {
+		return 0, fmt.Errorf("parts: length is unknown when trimming trailing whitespace")
+	}
+	if p.Config.EnsureTrailingNewline {
+		return 0, fmt.Errorf("parts: length is unknown when ensuring trailing newlines")
+	}
+	if p.Config.Decompress {
+		return 0, fmt.Errorf("parts: length is unknown when decompressing")
+	}
+	if p.Config.Charset != "" {
+		return 0, fmt.Errorf("parts: length is unknown when transcoding")
+	}
+	entries, err := p.readdir(context.Background(), 0)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for i, e := range entries {
+		if i > 0 {
+			total += int64(len(p.Config.Separator))
+		}
+		if p.Config.FileHeaderFunc != nil {
+			total += int64(len(p.Config.FileHeaderFunc(e.path)))
+		}
+		total += e.info.Size()
+	}
+
+	return total, nil
+}
+
+// Lookup returns the path of the file in paths that provides
+// basename according to the same precedence and filtering rules as
+// Readdirnames. It returns false if there is no such file or if the
+// paths cannot be traversed.
+func (p *Parts) Lookup(basename string) (string, bool) {
+	foundEntries, err := p.resolve(context.Background())
+	if err != nil {
+		return "", false
+	}
+	e, ok := foundEntries[basename]
+	if !ok {
+		return "", false
+	}
+
+	return e.path, true
+}
+
+// LookupInfo is like Lookup but returns the FileInfo of the file as
+// returned by Readdir. The bool is false if there is no such file. An
+// error is returned if the paths cannot be traversed, e.g., the status
+// of a file cannot be retrieved.
+func (p *Parts) LookupInfo(basename string) (FileInfo, bool, error) {
+	foundEntries, err := p.resolve(context.Background())
+	if err != nil {
+		return FileInfo{}, false, err
+	}
+	e, ok := foundEntries[basename]
+	if !ok {
+		return FileInfo{}, false, nil
+	}
+
+	return FileInfo{FileInfo: e.info, mode: e.mode}, true, nil
+}
+
+// Stat returns the FileInfo of the file with the given base name that
+// Readdir would return, i.e., the one that takes precedence. Fails if
+// no matching file is found.
+func (p *Parts) Stat(basename string) (FileInfo, error) {
+	foundEntries, err := p.resolve(context.Background())
+	if err != nil {
+		return FileInfo{}, err
+	}
+	e, ok := foundEntries[basename]
+	if !ok {
+		return FileInfo{}, fmt.Errorf("%w: %s", ErrNoMatch, basename)
+	}
+
+	return FileInfo{FileInfo: e.info, mode: e.mode}, nil
+}
+
+// Resolve returns the paths of all files matching the filters keyed
+// by base name. The paths for each base name are listed in order of
+// precedence, so the first one is the file returned by Readdirnames
+// and the rest are the files it shadows.
+func (p *Parts) Resolve() (map[string][]string, error) {
+	candidates, err := p.candidates(context.Background(), true, nil)
+	if err != nil {
+		return nil, err
+	}
+	paths := make(map[string][]string, len(candidates))
+	for name, entries := range candidates {
+		for _, e := range entries {
+			if err := p.absEntry(&e); err != nil {
+				return nil, err
+			}
+			paths[name] = append(paths[name], e.path)
+		}
 	}
 
-	return mode, nil
+	return paths, nil
 }
 
-type pathsByBasename []string
+// ResolvedMap returns the path of the file matching the filters that
+// takes precedence for each base name, keyed by base name, e.g., to
+// look up many base names without traversing paths each time. The
+// values are the paths returned by Readdirnames and Lookup; files
+// shadowed by them are omitted even if KeepDuplicates is set.
+func (p *Parts) ResolvedMap() (map[string]string, error) {
+	foundEntries, err := p.resolve(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	paths := make(map[string]string, len(foundEntries))
+	for name, e := range foundEntries {
+		paths[name] = e.path
+	}
 
-func (paths pathsByBasename) Len() int {
-	return len(paths)
+	return paths, nil
 }
 
-func (paths pathsByBasename) Swap(i, j int) {
-	paths[i], paths[j] = paths[j], paths[i]
+// Walk calls fn for each file in paths in "run-parts" order. The
+// files are resolved and sorted before fn is first called. Walk stops
+// and returns the error if fn returns a non-nil error.
+func (p *Parts) Walk(fn func(path string, mode FileMode) error) error {
+	entries, err := p.readdir(context.Background(), 0)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := fn(e.path, e.mode); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (paths pathsByBasename) Less(i, j int) bool {
-	return strings.Compare(filepath.Base(paths[i]), filepath.Base(paths[j])) < 0
+// FindFirst returns the first file in paths with the given base name
+// that passes the configured filters. Paths are scanned in order of
+// precedence, i.e., in reverse if LastWins is set, and the remaining
+// paths are not examined once a match is found. If Recursive is set
+// for any of the paths, they are traversed in full as by Lookup so
+// that files in subdirectories are found. Fails if basename is not a
+// base name, e.g., contains a path separator or is "..", so that files
+// outside of paths cannot be found.
+func (p *Parts) FindFirst(basename string) (string, error) {
+	if len(p.Paths) == 0 {
+		return "", ErrNoPaths
+	}
+	if filepath.Base(basename) != basename || basename == "." || basename == ".." {
+		return "", fmt.Errorf("parts: invalid base name: %q", basename)
+	}
+	if p.recursive() {
+		foundEntries, err := p.resolve(context.Background())
+		if err != nil {
+			return "", err
+		}
+		e, ok := foundEntries[basename]
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrNoMatch, basename)
+		}
+		return e.path, nil
+	}
+	for _, i := range p.pathIndexes() {
+		path := p.Paths[i]
+		c := p.pathConfig(i)
+		info, err := p.stat(path, true)
+		if err != nil && p.Config.IgnoreMissingDirs && os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", pathError(err)
+		}
+		isDir := info.IsDir()
+		var e entry
+		switch {
+		case isDir:
+			e, err = p.statEntry(p.join(path, basename), !c.NoFollowSymlinks)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return "", fmt.Errorf("parts: %w", err)
+			}
+		default:
+			if filepath.Base(path) != basename {
+				continue
+			}
+			e, err = p.statEntry(path, true)
+			if err != nil {
+				return "", fmt.Errorf("parts: %w", err)
+			}
+		}
+		ok, err := p.selectEntry(c, &e, isDir, nil)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return e.path, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrNoMatch, basename)
+}
+
+// AssertMode returns an error if the permissions of any of the files
+// in paths differ from wantPerm in the bits selected by mask. The
+// error lists every file that does not match.
+func (p *Parts) AssertMode(wantPerm FileMode, mask FileMode) error {
+	entries, err := p.readdir(context.Background(), 0)
+	if err != nil {
+		return err
+	}
+	var violations []string
+	for _, e := range entries {
+		if e.mode.Perm()&mask != wantPerm&mask {
+			violations = append(violations, fmt.Sprintf("%s (%s)", e.path, e.mode.Perm()))
+		}
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf(
+			"parts: files do not have mode %s: %s",
+			(wantPerm & mask).Perm(),
+			strings.Join(violations, ", "))
+	}
+
+	return nil
+}
+
+// Fragment holds the base name and contents of a single file
+// selected from the parts directories.
+type Fragment struct {
+	Name    string
+	Content []byte
+}
+
+// ContentMap returns the base name and full contents of each file in
+// paths in "run-parts" order. Unlike Read, the contents of every file
+// are held in memory at once so it should only be used when the total
+// size of the files is modest.
+func (p *Parts) ContentMap() ([]Fragment, error) {
+	entries, err := p.readdir(context.Background(), 0)
+	if err != nil {
+		return nil, err
+	}
+	fragments := make([]Fragment, 0, len(entries))
+	for _, e := range entries {
+		content := e.verified
+		if content == nil {
+			content, err = p.readFile(e.path)
+			if err != nil {
+				return nil, fmt.Errorf("parts: %w", err)
+			}
+		}
+		fragments = append(fragments, Fragment{Name: filepath.Base(e.path), Content: content})
+	}
+
+	return fragments, nil
+}
+
+// entry holds the data gathered for a file found while traversing
+// the parts directories.
+type entry struct {
+	path string
+	info os.FileInfo
+	mode FileMode
+
+	// verified holds the contents of the file if its signature was
+	// verified (RequireSignature) so that exactly the verified
+	// contents are read.
+	verified []byte
+}
+
+// readdir traverses paths and returns the filtered, de-duplicated
+// unless KeepDuplicates is set, and sorted list of entries. At most n
+// entries are returned if n > 0. The traversal is abandoned if ctx is
+// done.
+func (p *Parts) readdir(ctx context.Context, n int) ([]entry, error) {
+	candidates, err := p.candidates(ctx, p.Config.KeepDuplicates, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.sortEntries(candidates, n)
+}
+
+// sortEntries returns the entries in candidates sorted, with at most n
+// entries returned if n > 0. The paths are made absolute if
+// AbsolutePaths is set.
+func (p *Parts) sortEntries(candidates map[string][]entry, n int) ([]entry, error) {
+	entries := make([]entry, 0, len(candidates))
+	for _, val := range candidates {
+		entries = append(entries, val...)
+	}
+	// The sort is stable so that duplicates stay in order of
+	// precedence.
+	sorter := entrySorter{entries: entries, less: p.lessFunc()}
+	if p.Config.Reverse {
+		sort.Stable(sort.Reverse(sorter))
+	} else {
+		sort.Stable(sorter)
+	}
+	if n > 0 && n < len(entries) {
+		entries = entries[0:n]
+	}
+	for i := range entries {
+		if err := p.absEntry(&entries[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+// resolve traverses paths and returns the filtered entries keyed by
+// base name. Files from earlier paths take precedence over files with
+// the same base name from later paths unless LastWins is set. The
+// traversal is abandoned if ctx is done.
+func (p *Parts) resolve(ctx context.Context) (map[string]entry, error) {
+	candidates, err := p.candidates(ctx, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	foundEntries := make(map[string]entry, len(candidates))
+	for name, entries := range candidates {
+		e := entries[0]
+		if err := p.absEntry(&e); err != nil {
+			return nil, err
+		}
+		foundEntries[name] = e
+	}
+
+	return foundEntries, nil
+}
+
+// absEntry converts the path of e to an absolute path if
+// AbsolutePaths is set.
+func (p *Parts) absEntry(e *entry) error {
+	if !p.Config.AbsolutePaths || p.fsys != nil {
+		return nil
+	}
+	path, err := filepath.Abs(e.path)
+	if err != nil {
+		return fmt.Errorf("parts: %w", err)
+	}
+	e.path = path
+
+	return nil
+}
+
+// candidates traverses paths and returns the filtered entries keyed
+// by base name in order of precedence. If all is false, only the
+// first entry for each base name is kept and shadowed files are not
+// examined. The traversal is abandoned if ctx is done.
+func (p *Parts) candidates(ctx context.Context, all bool, excluded *[]Exclusion) (map[string][]entry, error) {
+	if len(p.Paths) == 0 {
+		return nil, ErrNoPaths
+	}
+	for i := range p.Paths {
+		c := p.pathConfig(i)
+		if err := c.validateGlob(); err != nil {
+			return nil, err
+		}
+		if err := c.validateOwner(); err != nil {
+			return nil, err
+		}
+	}
+	var warnings []error
+	defer func() {
+		p.mu.Lock()
+		p.warnings = warnings
+		p.mu.Unlock()
+	}()
+	foundEntries := make(map[string][]entry)
+	for _, i := range p.pathIndexes() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		path := p.Paths[i]
+		c := p.pathConfig(i)
+		e, err := p.statEntry(path, true)
+		if err != nil && p.Config.IgnoreMissingDirs && os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			err = pathError(err)
+			if p.skipError(err, &warnings) {
+				continue
+			}
+			return nil, err
+		}
+		switch {
+		case e.mode.IsDir():
+			if err := p.resolveDir(ctx, c, path, foundEntries, all, &warnings, excluded); err != nil {
+				return nil, err
+			}
+		default:
+			e.path = p.clean(path)
+			if err := p.addEntry(c, foundEntries, e, false, all, &warnings, excluded); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return foundEntries, nil
+}
+
+// resolveDir adds the entries in dir filtered according to c to
+// foundEntries as described by addEntry. If Recursive is set,
+// subdirectories are traversed breadth first so that files closer to
+// dir take precedence.
+func (p *Parts) resolveDir(ctx context.Context, c *Config, dir string, foundEntries map[string][]entry, all bool, warnings *[]error, excluded *[]Exclusion) error {
+	dirs := []string{dir}
+	for depth := 0; len(dirs) > 0; depth++ {
+		var subdirs []string
+		for _, dir := range dirs {
+			var dirSubdirs []string
+			var entryErr error
+			err := p.readDirEntries(ctx, dir, !c.NoFollowSymlinks, func(fileNames []string, entries []entry, errs []error) error {
+				for i, fileName := range fileNames {
+					if entryErr = ctx.Err(); entryErr != nil {
+						return entryErr
+					}
+					fullPath := p.join(dir, fileName)
+					e, err := entries[i], errs[i]
+					if err != nil && c.RegularOrSymlinkToRegular && os.IsNotExist(err) {
+						// A broken symbolic link does not
+						// resolve to a regular file.
+						if excluded != nil {
+							*excluded = append(*excluded, Exclusion{Path: fullPath, Reason: ReasonTypeMiss})
+						}
+						continue
+					}
+					if err != nil {
+						if warning := p.brokenSymlinkWarning(c, fullPath, err); warning != nil {
+							*warnings = append(*warnings, warning)
+							continue
+						}
+						entryErr = fmt.Errorf("parts: %w", err)
+						if p.skipError(entryErr, warnings) {
+							entryErr = nil
+							continue
+						}
+						return entryErr
+					}
+					if p.descend(c, e, depth) {
+						dirSubdirs = append(dirSubdirs, fullPath)
+					}
+					if entryErr = p.addEntry(c, foundEntries, e, true, all, warnings, excluded); entryErr != nil {
+						return entryErr
+					}
+				}
+				return nil
+			})
+			if entryErr != nil {
+				return entryErr
+			}
+			if err != nil {
+				err = fmt.Errorf("parts: %w", err)
+				if !p.skipError(err, warnings) {
+					return err
+				}
+			}
+			sort.Strings(dirSubdirs)
+			subdirs = append(subdirs, dirSubdirs...)
+		}
+		dirs = subdirs
+	}
+
+	return nil
+}
+
+// addEntry appends e to the entries in foundEntries with the same
+// base name if it passes selectEntry with c. If all is false, e is
+// skipped without being examined when an entry with the same base
+// name is already present. The name regexps are only checked if
+// matchName is true. Files excluded for security reasons are added to
+// warnings. Excluded files are added to excluded along with the reason
+// if it is not nil.
+func (p *Parts) addEntry(c *Config, foundEntries map[string][]entry, e entry, matchName bool, all bool, warnings *[]error, excluded *[]Exclusion) error {
+	name := filepath.Base(e.path)
+	if _, ok := foundEntries[name]; ok && !all {
+		return nil
+	}
+	reason, err := p.selectReason(c, &e, matchName, warnings)
+	if err != nil {
+		if p.skipError(err, warnings) {
+			return nil
+		}
+		return err
+	}
+	switch {
+	case reason == ReasonIncluded:
+		foundEntries[name] = append(foundEntries[name], e)
+	case excluded != nil:
+		*excluded = append(*excluded, Exclusion{Path: e.path, Reason: reason})
+	}
+
+	return nil
+}
+
+// skipError returns true if err should be added to warnings instead
+// of ending the traversal because ContinueOnError is set.
+func (p *Parts) skipError(err error, warnings *[]error) bool {
+	if !p.Config.ContinueOnError {
+		return false
+	}
+	*warnings = append(*warnings, err)
+
+	return true
+}
+
+// descend returns true if the directory traversal configured by c
+// should descend into the file described by e found depth levels
+// below a path. Symbolic links to directories are not followed to
+// avoid cycles.
+func (p *Parts) descend(c *Config, e entry, depth int) bool {
+	if !c.Recursive || !e.mode.IsDir() {
+		return false
+	}
+	if c.MaxDepth > 0 && depth >= c.MaxDepth {
+		return false
+	}
+	info, err := p.stat(e.path, false)
+
+	return err == nil && info.IsDir()
+}
+
+// Read reads the contents of the parts directory into buffer b. It is
+// safe to call Read from multiple goroutines; the calls are
+// serialized and each byte is returned to only one of them.
+func (p *Parts) Read(b []byte) (int, error) {
+	p.readMu.Lock()
+	defer p.readMu.Unlock()
+	if p.readState == nil {
+		state, err := p.newReadState()
+		if err != nil {
+			return 0, err
+		}
+		p.readState = state
+	}
+
+	bytesRead, err := p.readState.Reader.Read(b)
+
+	return bytesRead, err
+}
+
+// Seek sets the offset for the next Read to offset bytes from the
+// start of the concatenated contents of the parts directory. Only
+// io.SeekStart is supported; an error is returned for other whence
+// values and negative offsets. Seeking re-opens the files as Reset
+// does and skips offset bytes by reading them, so Seek(0,
+// io.SeekStart) is cheap but other offsets are not. Seeking beyond
+// the end is allowed; the next Read returns io.EOF.
+func (p *Parts) Seek(offset int64, whence int) (int64, error) {
+	if whence != io.SeekStart {
+		return 0, fmt.Errorf("parts: unsupported seek whence: %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("parts: negative seek offset: %d", offset)
+	}
+	p.readMu.Lock()
+	defer p.readMu.Unlock()
+	_ = p.closeReadState()
+	if offset == 0 {
+		return 0, nil
+	}
+	state, err := p.newReadState()
+	if err != nil {
+		return 0, err
+	}
+	p.readState = state
+	_, err = io.CopyN(ioutil.Discard, p.readState.Reader, offset)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	return offset, nil
+}
+
+// Open returns a reader over the contents of the parts directory as
+// produced by Read. Each call returns an independent reader with its
+// own open files that does not affect, and is not affected by, Read
+// or other readers, so several can be used concurrently. The caller
+// must close the reader.
+func (p *Parts) Open() (io.ReadCloser, error) {
+	state, err := p.newReadState()
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// newReadState opens the files in the parts directory and returns a
+// reader over their concatenated contents. Any files already opened
+// are closed if there is an error.
+func (p *Parts) newReadState() (*readState, error) {
+	entries, err := p.readdir(context.Background(), 0)
+	if err != nil {
+		return nil, err
+	}
+	useCache := p.useCache(entries)
+	// Create a reader for each file and stuff it away.
+	state := new(readState)
+	state.Files = make([]io.ReadCloser, 0, len(entries))
+	state.Paths = make([]string, 0, len(entries))
+	for _, e := range entries {
+		file, err := p.openEntry(e, useCache)
+		if err != nil {
+			_ = state.Close()
+			return nil, err
+		}
+		state.Files = append(state.Files, file)
+		state.Paths = append(state.Paths, e.path)
+	}
+	reader := &fileReader{onStart: p.Config.OnFileStart}
+	for i, file := range state.Files {
+		path := entries[i].path
+		if i > 0 && len(p.Config.Separator) > 0 {
+			reader.add(path, bytes.NewReader(p.Config.Separator))
+		}
+		if p.Config.FileHeaderFunc != nil {
+			reader.add(path, bytes.NewReader(p.Config.FileHeaderFunc(path)))
+		}
+		reader.add(path, file)
+	}
+	state.Reader = reader
+
+	return state, nil
+}
+
+// WriteTo writes the contents of the parts directory to w. Each file
+// is opened, copied, and closed in turn. It implements io.WriterTo so
+// that io.Copy can avoid the intermediate buffering done by Read. If
+// a read by Read is in progress, the rest of its contents are written
+// instead, leaving the next Read at io.EOF until Close or Reset.
+func (p *Parts) WriteTo(w io.Writer) (int64, error) {
+	p.readMu.Lock()
+	defer p.readMu.Unlock()
+	if p.readState != nil {
+		return io.Copy(w, p.readState.Reader)
+	}
+
+	return p.writeAll(w)
+}
+
+// writeAll writes the contents of the parts directory to w from the
+// beginning, ignoring any read in progress.
+func (p *Parts) writeAll(w io.Writer) (int64, error) {
+	entries, err := p.readdir(context.Background(), 0)
+	if err != nil {
+		return 0, err
+	}
+	useCache := p.useCache(entries)
+	var total int64
+	for i, e := range entries {
+		if i > 0 && len(p.Config.Separator) > 0 {
+			written, err := w.Write(p.Config.Separator)
+			total += int64(written)
+			if err != nil {
+				return total, err
+			}
+		}
+		if p.Config.FileHeaderFunc != nil {
+			written, err := w.Write(p.Config.FileHeaderFunc(e.path))
+			total += int64(written)
+			if err != nil {
+				return total, err
+			}
+		}
+		written, err := p.copyEntry(w, e, useCache)
+		total += written
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// Bytes returns the concatenated contents of the parts directory as
+// written by WriteTo from the beginning, ignoring any read in progress.
+// No files are left open when it returns, even if there is an error.
+func (p *Parts) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := p.writeAll(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ReadAll returns the concatenated contents of the parts directory
+// from the beginning, as returned by Read, and calls Close before
+// returning, even if there is an error, so that the caller does not
+// need to manage buffers or close any files. Any reading in progress
+// by Read is abandoned. It is the simplest way to read the contents.
+func (p *Parts) ReadAll() ([]byte, error) {
+	b, err := p.Bytes()
+	closeErr := p.Close()
+	if err != nil {
+		return nil, err
+	}
+	if closeErr != nil {
+		return nil, closeErr
+	}
+
+	return b, nil
+}
+
+// Lines returns the concatenated contents of the parts directory as
+// written by WriteTo split into lines. The newline characters are
+// removed and a trailing empty line is dropped.
+func (p *Parts) Lines() ([]string, error) {
+	b, err := p.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines, nil
+}
+
+// copyEntry copies the contents of the file described by e to w.
+func (p *Parts) copyEntry(w io.Writer, e entry, useCache bool) (int64, error) {
+	file, err := p.openEntry(e, useCache)
+	if err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(w, file)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	return written, err
+}
+
+// openEntry returns a reader for the contents of the file described
+// by e with any configured transformations applied.
+func (p *Parts) openEntry(e entry, useCache bool) (io.ReadCloser, error) {
+	file, err := p.openContent(e, useCache)
+	if err != nil {
+		return nil, err
+	}
+	if p.Config.Decompress && strings.HasSuffix(e.path, ".gz") {
+		zfile, err := newGzipReadCloser(file)
+		if err != nil {
+			return nil, fmt.Errorf("parts: %s: %w", e.path, err)
+		}
+		file = zfile
+	}
+	if p.Config.Charset != "" {
+		reader, err := newCharsetReader(file, p.Config.Charset)
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		file = readCloser{Reader: reader, Closer: file}
+	}
+	if p.Config.TrimTrailingWhitespace {
+		file = readCloser{Reader: newTrimReader(file), Closer: file}
+	}
+	if p.Config.EnsureTrailingNewline {
+		file = readCloser{Reader: &newlineReader{r: file}, Closer: file}
+	}
+
+	return file, nil
+}
+
+// openContent returns a reader for the contents of the file described
+// by e. The verified contents are served if its signature was
+// verified. Otherwise the contents are served from and saved to the
+// content cache if useCache is true.
+func (p *Parts) openContent(e entry, useCache bool) (io.ReadCloser, error) {
+	if e.verified != nil {
+		return ioutil.NopCloser(bytes.NewReader(e.verified)), nil
+	}
+	if !useCache {
+		return p.openFile(e.path)
+	}
+	p.mu.Lock()
+	content, ok := p.cache.get(e)
+	p.mu.Unlock()
+	if ok {
+		return ioutil.NopCloser(bytes.NewReader(content)), nil
+	}
+	file, err := p.openFile(e.path)
+	if err != nil {
+		return nil, err
+	}
+	content, err = ioutil.ReadAll(file)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	if p.cache != nil {
+		p.cache.put(e, content)
+	}
+	p.mu.Unlock()
+
+	return ioutil.NopCloser(bytes.NewReader(content)), nil
+}
+
+// useCache returns true if the contents of entries fit in the
+// content cache. The cache is cleared if they do not, otherwise the
+// contents of files not described by entries are removed from it.
+func (p *Parts) useCache(entries []entry) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Config.ContentCacheBytes <= 0 {
+		p.cache = nil
+		return false
+	}
+	var total int64
+	for _, e := range entries {
+		total += e.info.Size()
+	}
+	if total > p.Config.ContentCacheBytes {
+		p.cache = nil
+		return false
+	}
+	if p.cache == nil {
+		p.cache = make(contentCache)
+	}
+	p.cache.prune(entries)
+
+	return true
+}
+
+// Close closes all files opened by Read.
+func (p *Parts) Close() error {
+	p.readMu.Lock()
+	defer p.readMu.Unlock()
+
+	return p.closeReadState()
+}
+
+// closeReadState closes all files opened by Read. The caller must
+// hold readMu.
+func (p *Parts) closeReadState() error {
+	if p.readState == nil {
+		return nil
+	}
+	err := p.readState.Close()
+	p.readState = nil
+
+	return err
+}
+
+// Peek returns the path of the file whose contents, including any
+// separator or header preceding it, are being returned by Read, or of
+// the next file as soon as every byte of the current file has been
+// returned. It returns false if Read has not been called since the
+// files were last closed or if all of the files have been read. It may
+// read ahead by one byte, which is returned by the next Read.
+func (p *Parts) Peek() (string, bool) {
+	p.readMu.Lock()
+	defer p.readMu.Unlock()
+	if p.readState == nil {
+		return "", false
+	}
+
+	return p.readState.Reader.path()
+}
+
+// OpenFiles returns the paths of the files opened by Read that have
+// not yet been closed by Close or Reset. It returns an empty list if
+// Read has not been called since the files were last closed.
+func (p *Parts) OpenFiles() []string {
+	p.readMu.Lock()
+	defer p.readMu.Unlock()
+	if p.readState == nil {
+		return []string{}
+	}
+
+	return append([]string{}, p.readState.Paths...)
+}
+
+// Reset closes any files opened by Read so that the next Read starts
+// again from the beginning of the parts directory. Errors closing the
+// files are ignored; use Close to check them.
+func (p *Parts) Reset() {
+	_ = p.Close()
+}
+
+// brokenSymlinkWarning returns a warning if err resulted from name
+// being a symbolic link to a nonexistent file and such links are to
+// be skipped according to c. It returns nil if the file is not to be
+// skipped.
+func (p *Parts) brokenSymlinkWarning(c *Config, name string, err error) error {
+	if !c.SkipBrokenSymlinks || !os.IsNotExist(err) {
+		return nil
+	}
+	info, lstatErr := p.stat(name, false)
+	if lstatErr != nil || info.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("parts: skipped broken symlink: %w", err)
+}
+
+// Warnings returns the non-fatal problems encountered by the most
+// recent traversal of the parts directories, e.g., skipped broken
+// symbolic links, world-writable files, and errors skipped because
+// ContinueOnError is set.
+func (p *Parts) Warnings() []error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.warnings
+}
+
+// statEntry returns the entry for the named file. Symbolic links are
+// followed if follow is true. If there is an error, it will be of
+// type *PathError.
+func (p *Parts) statEntry(name string, follow bool) (entry, error) {
+	info, err := p.stat(name, follow)
+	if err != nil {
+		return entry{}, err
+	}
+
+	return entry{path: name, info: info, mode: ModeFromFileInfo(info)}, nil
+}
+
+// readDirEntries calls fn with the names of the files in dir along
+// with the results of statEntries for them. The names are passed in
+// chunks of ReadDirChunkSize if it is set, otherwise fn is called
+// once. The names are served from and saved to the stat cache if
+// CacheStats is set. If fn returns an error, it is returned.
+func (p *Parts) readDirEntries(ctx context.Context, dir string, follow bool, fn func(fileNames []string, entries []entry, errs []error) error) error {
+	if !p.Config.CacheStats {
+		return p.readDirNamesChunked(dir, p.Config.ReadDirChunkSize, func(fileNames []string) error {
+			entries, errs := p.statEntries(ctx, dir, fileNames, follow)
+			return fn(fileNames, entries, errs)
+		})
+	}
+	info, err := p.stat(dir, true)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	fileNames, ok := p.statCache.get(dir, info.ModTime())
+	p.mu.Unlock()
+	if !ok {
+		fileNames, err = p.readDirNames(dir)
+		if err != nil {
+			return err
+		}
+		p.mu.Lock()
+		if p.statCache == nil {
+			p.statCache = make(statCache)
+		}
+		p.statCache.put(dir, info.ModTime(), fileNames)
+		p.mu.Unlock()
+	}
+	entries, errs := p.statEntries(ctx, dir, fileNames, follow)
+
+	return fn(fileNames, entries, errs)
+}
+
+// statEntries returns the entries for the named files in dir and the
+// errors retrieving them at the same indexes. Up to Concurrency files
+// are examined at once. Files are not examined once ctx is
+// done. Symbolic links are followed if follow is true.
+func (p *Parts) statEntries(ctx context.Context, dir string, fileNames []string, follow bool) ([]entry, []error) {
+	entries := make([]entry, len(fileNames))
+	errs := make([]error, len(fileNames))
+	stat := func(i int) {
+		if ctx.Err() != nil {
+			return
+		}
+		entries[i], errs[i] = p.statEntry(p.join(dir, fileNames[i]), follow)
+	}
+	workers := p.Config.Concurrency
+	if workers > len(fileNames) {
+		workers = len(fileNames)
+	}
+	if workers < 2 {
+		for i := range fileNames {
+			stat(i)
+		}
+		return entries, errs
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				stat(i)
+			}
+		}()
+	}
+	for i := range fileNames {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return entries, errs
+}
+
+// selectEntry returns true if the file described by e should be
+// included according to c. The name regexps are only checked if
+// matchName is true. Files excluded for security reasons are added to
+// warnings if it is not nil. The verified contents of e are set if
+// its signature is checked.
+func (p *Parts) selectEntry(c *Config, e *entry, matchName bool, warnings *[]error) (bool, error) {
+	reason, err := p.selectReason(c, e, matchName, warnings)
+
+	return reason == ReasonIncluded, err
+}
+
+// selectReason is like selectEntry but returns the reason the file is
+// excluded, or ReasonIncluded if it is not.
+func (p *Parts) selectReason(c *Config, e *entry, matchName bool, warnings *[]error) (FilterReason, error) {
+	if reason := p.filterReason(c, *e, matchName); reason != ReasonIncluded {
+		return reason, nil
+	}
+	if matchName && p.disabled(c, *e) {
+		return ReasonDisabled, nil
+	}
+	if c.RegularOrSymlinkToRegular && !p.resolvesToRegular(*e) {
+		return ReasonTypeMiss, nil
+	}
+	if c.RejectWorldWritable && e.mode&ModeSymlink == 0 && e.mode&0002 != 0 {
+		if warnings != nil {
+			*warnings = append(*warnings, fmt.Errorf("parts: skipped world-writable file: %s", e.path))
+		}
+		return ReasonWorldWritable, nil
+	}
+	if c.RejectSetuid && e.mode&ModeSymlink == 0 && (e.mode.IsSetuid() || e.mode.IsSetgid()) {
+		if warnings != nil {
+			*warnings = append(*warnings, fmt.Errorf("parts: skipped setuid or setgid file: %s", e.path))
+		}
+		return ReasonSetuid, nil
+	}
+	if c.RequireSignature {
+		ok, err := p.verifySignature(c, e)
+		if err != nil || !ok {
+			return ReasonBadSignature, err
+		}
+	}
+
+	return ReasonIncluded, nil
+}
+
+// filterReason returns ReasonIncluded if the file described by e
+// matches the filtering criteria of c (name regexps, perms, mode,
+// modification time, size, and owner), otherwise the reason it is
+// excluded. The name regexps are only checked if matchName is true.
+func (p *Parts) filterReason(c *Config, e entry, matchName bool) FilterReason {
+	if matchName {
+		if reason := c.nameReason(e.path); reason != ReasonIncluded {
+			return reason
+		}
+	}
+	if reason := c.modeReason(e.mode); reason != ReasonIncluded {
+		return reason
+	}
+	modTime := e.info.ModTime()
+	if !c.ModifiedAfter.IsZero() && !modTime.After(c.ModifiedAfter) {
+		return ReasonModTime
+	}
+	if !c.ModifiedBefore.IsZero() && !modTime.Before(c.ModifiedBefore) {
+		return ReasonModTime
+	}
+	if e.info.Size() < c.MinSize {
+		return ReasonSize
+	}
+	if c.MaxSize > 0 && e.info.Size() > c.MaxSize {
+		return ReasonSize
+	}
+	if c.FilterOwner || c.FilterGroup {
+		uid, gid, ok := fileOwner(e.info)
+		if !ok {
+			return ReasonOwner
+		}
+		if c.FilterOwner && uid != c.OwnerUID {
+			return ReasonOwner
+		}
+		if c.FilterGroup && gid != c.OwnerGID {
+			return ReasonOwner
+		}
+	}
+
+	return ReasonIncluded
+}
+
+// resolvesToRegular reports whether e is a regular file or a symbolic
+// link that resolves to one.
+func (p *Parts) resolvesToRegular(e entry) bool {
+	e, err := p.followEntry(e)
+
+	return err == nil && e.mode.IsRegular()
+}
+
+// followEntry returns the entry describing the file e refers to if it
+// is a symbolic link, otherwise e itself.
+func (p *Parts) followEntry(e entry) (entry, error) {
+	if e.mode&ModeSymlink == 0 {
+		return e, nil
+	}
+	target, err := p.statEntry(e.path, true)
+	target.verified = e.verified
+
+	return target, err
+}
+
+// disabled reports whether a sibling marker file named after e with
+// c.DisabledSuffix appended exists.
+func (p *Parts) disabled(c *Config, e entry) bool {
+	if c.DisabledSuffix == "" {
+		return false
+	}
+	_, err := p.stat(e.path+c.DisabledSuffix, false)
+
+	return err == nil
+}
+
+// modeReason returns ReasonIncluded if mode matches the permission
+// and type filters of c, otherwise the reason it is excluded.
+func (c *Config) modeReason(mode FileMode) FilterReason {
+	if mode&c.ModePermFilter == 0 {
+		return ReasonPermMiss
+	}
+	if mode&c.ModeTypeFilter == 0 && !(c.IncludeDirs && mode.IsDir()) {
+		return ReasonTypeMiss
+	}
+
+	return ReasonIncluded
+}
+
+// nameReason returns ReasonIncluded if the base name of path matches
+// none of the exclude regexps of c and at least one of the include
+// regexps, otherwise the reason it is excluded. RegExpFilter is
+// matched against path itself if RegExpMatchesFullPath is set.
+// Excludes are checked first so that exclusion always wins. Hidden
+// names, invalid LSB names, and disabled names are rejected if
+// ExcludeHidden, LSBNames, and DisabledSuffix are set, and names must
+// also match GlobFilter and Extensions if they are set.
+func (c *Config) nameReason(path string) FilterReason {
+	name := filepath.Base(path)
+	if c.ExcludeHidden && strings.HasPrefix(name, ".") {
+		return ReasonHidden
+	}
+	if c.LSBNames && !IsLSBName(name) {
+		return ReasonNotLSBName
+	}
+	if c.DisabledSuffix != "" && strings.HasSuffix(name, c.DisabledSuffix) {
+		return ReasonDisabled
+	}
+	for _, regExp := range c.ExcludeRegExps {
+		if regExp.MatchString(name) {
+			return ReasonExcludeMatch
+		}
+	}
+	if c.GlobFilter != "" {
+		if ok, _ := filepath.Match(c.GlobFilter, name); !ok {
+			return ReasonGlobMiss
+		}
+	}
+	if len(c.Extensions) > 0 && !matchExtension(c.Extensions, name) {
+		return ReasonExtensionMiss
+	}
+	if c.RegExpFilter == nil && len(c.IncludeRegExps) == 0 {
+		return ReasonIncluded
+	}
+	regExpName := name
+	if c.RegExpMatchesFullPath {
+		regExpName = path
+	}
+	if c.RegExpFilter != nil && c.RegExpFilter.MatchString(regExpName) {
+		return ReasonIncluded
+	}
+	for _, regExp := range c.IncludeRegExps {
+		if regExp.MatchString(name) {
+			return ReasonIncluded
+		}
+	}
+
+	return ReasonRegexMiss
+}
+
+// matchExtension reports whether the extension of name is one of
+// extensions, which may omit the leading ".".
+func matchExtension(extensions []string, name string) bool {
+	ext := filepath.Ext(name)
+	if ext == "" {
+		return false
+	}
+	for _, e := range extensions {
+		if e == ext || "."+e == ext {
+			return true
+		}
+	}
+
+	return false
+}
+
+// StatMode returns the FileMode for the named path. If there is an error,
+// it will be of type *PathError.
+func StatMode(name string) (FileMode, error) {
+	fileInfo, err := os.Stat(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return ModeFromFileInfo(fileInfo), nil
+}
+
+// LstatMode returns the FileMode for the named path. If the path is a
+// symbolic link, the returned FileMode describes the symbolic
+// link. If there is an error, it will be of type *PathError.
+func LstatMode(name string) (FileMode, error) {
+	fileInfo, err := os.Lstat(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return ModeFromFileInfo(fileInfo), nil
 }
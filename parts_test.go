@@ -5,11 +5,22 @@
 package parts_test
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/apatters/go-parts"
 	"github.com/stretchr/testify/assert"
@@ -382,3 +393,3002 @@ func TestLstatMode(t *testing.T) {
 	assert.True(t, mode&parts.ModeSymlink == 0)
 	assert.NoError(t, err)
 }
+
+func TestReaddir(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+
+	fileInfos, err := p.Readdir(0)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	require.Len(t, fileInfos, len(fileNames))
+	for i, fileInfo := range fileInfos {
+		t.Logf("fileInfo: %s %s %d", fileInfo.Name(), fileInfo.Mode(), fileInfo.Size())
+		assert.Equal(t, filepath.Base(fileNames[i]), fileInfo.Name())
+		assert.True(t, fileInfo.Mode().IsRegular())
+		assert.NotZero(t, fileInfo.Mode().Perm())
+		assert.NotZero(t, fileInfo.Size())
+	}
+
+	n := 3
+	fileInfos, err = p.Readdir(n)
+	t.Logf("n = %d", n)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Len(t, fileInfos, n)
+}
+
+func TestContentMap(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+
+	fragments, err := p.ContentMap()
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	require.Len(t, fragments, len(fileNames))
+	for i, fragment := range fragments {
+		t.Logf("fragment: %s %q", fragment.Name, fragment.Content)
+		assert.Equal(t, filepath.Base(fileNames[i]), fragment.Name)
+		assert.Equal(t, fragment.Name+"\n", string(fragment.Content))
+	}
+}
+
+func TestFindFirst(t *testing.T) {
+	p := parts.NewParts(testDataPaths, nil)
+	path, err := p.FindFirst("10-both.conf")
+	t.Logf("path: %s", path)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, "testdata/etc/10-both.conf", path)
+
+	path, err = p.FindFirst("10-only-lib.conf")
+	t.Logf("path: %s", path)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, "testdata/usr/lib/10-only-lib.conf", path)
+
+	path, err = p.FindFirst("test.conf")
+	t.Logf("path: %s", path)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, "testdata/test.conf", path)
+
+	path, err = p.FindFirst("notexist.conf")
+	t.Logf("path: %s", path)
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+	assert.Empty(t, path)
+}
+
+func TestFindFirstTraversal(t *testing.T) {
+	p := parts.NewParts([]string{"testdata/etc"}, nil)
+	for _, name := range []string{
+		"../../../../../../etc/passwd",
+		"../usr/lib/10-only-lib.conf",
+		"sub/10-both.conf",
+		"/etc/passwd",
+		"..",
+		".",
+		"",
+	} {
+		path, err := p.FindFirst(name)
+		t.Logf("name: %q, path: %s, err: %v", name, path, err)
+		assert.Error(t, err)
+		assert.False(t, errors.Is(err, parts.ErrNoMatch))
+		assert.Empty(t, path)
+	}
+}
+
+func TestConfigClone(t *testing.T) {
+	config, err := parts.NewConfigWithFilters(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		[]string{`\.conf$`},
+		[]string{`^20-`})
+	require.NoError(t, err)
+	config.Extensions = []string{".conf"}
+	config.Separator = []byte("\n")
+
+	clone := config.Clone()
+	t.Logf("clone: %v", clone)
+	assert.Equal(t, config, clone)
+
+	clone.Reverse = true
+	clone.IncludeRegExps[0] = regexp.MustCompile(`\.yaml$`)
+	clone.ExcludeRegExps = append(clone.ExcludeRegExps, regexp.MustCompile(`^30-`))
+	clone.Extensions[0] = ".yaml"
+	clone.Separator[0] = ';'
+	assert.False(t, config.Reverse)
+	assert.Equal(t, `\.conf$`, config.IncludeRegExps[0].String())
+	assert.Len(t, config.ExcludeRegExps, 1)
+	assert.Equal(t, []string{".conf"}, config.Extensions)
+	assert.Equal(t, []byte("\n"), config.Separator)
+
+	var nilConfig *parts.Config
+	assert.Nil(t, nilConfig.Clone())
+}
+
+func TestWalkIncludeExclude(t *testing.T) {
+	config, err := parts.NewConfigWithFilters(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		[]string{`\.conf$`, `^40-`},
+		[]string{`^10-`, `symlink`})
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.EqualValues(
+		t,
+		[]string{
+			"testdata/etc/20-only-etc.conf",
+			"testdata/usr/lib/20-only-lib.conf",
+			"testdata/usr/lib/40-noconf",
+			"testdata/usr/lib/nodigits.conf",
+			"testdata/test.conf",
+		},
+		fileNames)
+
+	_, err = parts.NewConfigWithFilters(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		nil,
+		[]string{`(`})
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+}
+
+func TestWriteTo(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+
+	expectedContents := ""
+	for _, fileName := range fileNames {
+		expectedContents += filepath.Base(fileName) + "\n"
+	}
+
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, p)
+	t.Logf("n: %d", n)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	t.Logf("expectedContents:\n%s", expectedContents)
+	t.Logf("contents:\n%s", buf.String())
+	assert.EqualValues(t, len(expectedContents), n)
+	assert.EqualValues(t, expectedContents, buf.String())
+}
+
+func TestReadContentCache(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	config.ContentCacheBytes = 1024
+
+	p := parts.NewParts(testDataPaths, config)
+	opened := 0
+	parts.SetOpenFunc(p, func(name string) (io.ReadCloser, error) {
+		opened++
+		return os.Open(name)
+	})
+
+	first, err := ioutil.ReadAll(p)
+	t.Logf("opened: %d", opened)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	require.NoError(t, p.Close())
+	assert.Equal(t, len(testDataConfigFiles), opened)
+
+	opened = 0
+	second, err := ioutil.ReadAll(p)
+	t.Logf("opened: %d", opened)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	require.NoError(t, p.Close())
+	assert.Zero(t, opened)
+	assert.Equal(t, string(first), string(second))
+
+	config.ContentCacheBytes = 1
+	opened = 0
+	third, err := ioutil.ReadAll(p)
+	t.Logf("opened: %d", opened)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	require.NoError(t, p.Close())
+	assert.Equal(t, len(testDataConfigFiles), opened)
+	assert.Equal(t, string(first), string(third))
+}
+
+func TestReadContentCacheInvalidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "10-test.conf")
+	require.NoError(t, ioutil.WriteFile(fileName, []byte("first\n"), 0644))
+
+	config := parts.NewDefaultConfig()
+	config.ContentCacheBytes = 1024
+	p := parts.NewParts([]string{dir}, config)
+	opened := 0
+	parts.SetOpenFunc(p, func(name string) (io.ReadCloser, error) {
+		opened++
+		return os.Open(name)
+	})
+
+	var buf bytes.Buffer
+	_, err = p.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, "first\n", buf.String())
+	assert.Equal(t, 1, opened)
+
+	require.NoError(t, ioutil.WriteFile(fileName, []byte("second\n"), 0644))
+	modTime := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(fileName, modTime, modTime))
+	buf.Reset()
+	_, err = p.WriteTo(&buf)
+	t.Logf("opened: %d", opened)
+	require.NoError(t, err)
+	assert.Equal(t, "second\n", buf.String())
+	assert.Equal(t, 2, opened)
+}
+
+func TestReadContentCachePrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	firstName := filepath.Join(dir, "10-first.conf")
+	secondName := filepath.Join(dir, "20-second.conf")
+	require.NoError(t, ioutil.WriteFile(firstName, []byte("first\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(secondName, []byte("second\n"), 0644))
+
+	config := parts.NewDefaultConfig()
+	config.ContentCacheBytes = 1024
+	p := parts.NewParts([]string{dir}, config)
+	var buf bytes.Buffer
+	_, err = p.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, "first\nsecond\n", buf.String())
+	assert.Equal(t, 2, parts.ContentCacheLen(p))
+
+	// The contents of removed files are dropped from the cache.
+	require.NoError(t, os.Remove(firstName))
+	buf.Reset()
+	_, err = p.WriteTo(&buf)
+	t.Logf("cached: %d", parts.ContentCacheLen(p))
+	require.NoError(t, err)
+	assert.Equal(t, "second\n", buf.String())
+	assert.Equal(t, 1, parts.ContentCacheLen(p))
+}
+
+func TestAssertMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	goodFile := filepath.Join(dir, "10-good.conf")
+	badFile := filepath.Join(dir, "20-bad.conf")
+	require.NoError(t, ioutil.WriteFile(goodFile, []byte("good\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(badFile, []byte("bad\n"), 0644))
+	require.NoError(t, os.Chmod(goodFile, 0644))
+	require.NoError(t, os.Chmod(badFile, 0666))
+
+	p := parts.NewParts([]string{dir}, nil)
+	err = p.AssertMode(0644, parts.ModePerm)
+	t.Logf("err: %v", err)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), badFile)
+	assert.NotContains(t, err.Error(), goodFile)
+
+	err = p.AssertMode(0644, 0755)
+	t.Logf("err: %v", err)
+	assert.NoError(t, err)
+}
+
+func TestReset(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	defer p.Close()
+	p.Reset()
+
+	first, err := ioutil.ReadAll(p)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	require.NotEmpty(t, first)
+
+	b, err := ioutil.ReadAll(p)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Empty(t, b)
+
+	p.Reset()
+	second, err := ioutil.ReadAll(p)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, string(first), string(second))
+}
+
+func TestReadSeparator(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	config.Separator = []byte("--\n")
+
+	p := parts.NewParts(testDataPaths, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+
+	expectedContents := ""
+	for i, fileName := range fileNames {
+		if i > 0 {
+			expectedContents += "--\n"
+		}
+		expectedContents += filepath.Base(fileName) + "\n"
+	}
+
+	defer p.Close()
+	b, err := ioutil.ReadAll(p)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	t.Logf("expectedContents:\n%s", expectedContents)
+	t.Logf("contents:\n%s", string(b))
+	assert.EqualValues(t, expectedContents, string(b))
+
+	// WriteTo continues a read in progress, so start over.
+	require.NoError(t, p.Close())
+	var buf bytes.Buffer
+	n, err := p.WriteTo(&buf)
+	t.Logf("n: %d", n)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.EqualValues(t, len(expectedContents), n)
+	assert.EqualValues(t, expectedContents, buf.String())
+}
+
+func TestWriteToAfterRead(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	defer p.Close()
+	expectedContents, err := p.Bytes()
+	require.NoError(t, err)
+
+	b := make([]byte, 5)
+	n, err := p.Read(b)
+	t.Logf("n: %d, err: %v", n, err)
+	require.NoError(t, err)
+	require.Equal(t, 5, n)
+
+	var buf bytes.Buffer
+	written, err := io.Copy(&buf, p)
+	t.Logf("written: %d, err: %v", written, err)
+	t.Logf("contents: %q", buf.String())
+	require.NoError(t, err)
+	assert.EqualValues(t, len(expectedContents)-5, written)
+	assert.Equal(t, string(expectedContents), string(b)+buf.String())
+
+	// p is left at EOF.
+	n, err = p.Read(b)
+	t.Logf("n: %d, err: %v", n, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReadTrimTrailingWhitespace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, "10-first.conf"),
+		[]byte("a = 1  \n\tb =\t2\t\n  \n"),
+		0644))
+	require.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, "20-second.conf"),
+		[]byte("c = 3 \r\nd = 4 \t "),
+		0644))
+
+	config := parts.NewDefaultConfig()
+	config.TrimTrailingWhitespace = true
+	p := parts.NewParts([]string{dir}, config)
+	expectedContents := "a = 1\n\tb =\t2\n\nc = 3\r\nd = 4"
+
+	defer p.Close()
+	b, err := ioutil.ReadAll(p)
+	t.Logf("err: %v", err)
+	t.Logf("contents: %q", string(b))
+	require.NoError(t, err)
+	assert.Equal(t, expectedContents, string(b))
+
+	// WriteTo continues a read in progress, so start over.
+	require.NoError(t, p.Close())
+	var buf bytes.Buffer
+	_, err = p.WriteTo(&buf)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, expectedContents, buf.String())
+}
+
+func TestReadEnsureTrailingNewline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for name, contents := range map[string]string{
+		"10-missing.conf":  "a = 1",
+		"20-present.conf":  "b = 2\n",
+		"30-extra.conf":    "c = 3\n\n\n",
+		"40-empty.conf":    "",
+		"50-crlf.conf":     "d = 4\r\n\r\n",
+		"60-internal.conf": "e = 5\n\nf = 6",
+	} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644))
+	}
+
+	config := parts.NewDefaultConfig()
+	config.EnsureTrailingNewline = true
+	p := parts.NewParts([]string{dir}, config)
+	expectedContents := "a = 1\nb = 2\nc = 3\nd = 4\r\ne = 5\n\nf = 6\n"
+
+	defer p.Close()
+	b, err := ioutil.ReadAll(p)
+	t.Logf("err: %v", err)
+	t.Logf("contents: %q", string(b))
+	require.NoError(t, err)
+	assert.Equal(t, expectedContents, string(b))
+
+	// WriteTo continues a read in progress, so start over.
+	require.NoError(t, p.Close())
+	var buf bytes.Buffer
+	_, err = p.WriteTo(&buf)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, expectedContents, buf.String())
+
+	// Small reads see the same contents.
+	require.NoError(t, p.Close())
+	var small []byte
+	one := make([]byte, 1)
+	for {
+		n, err := p.Read(one)
+		small = append(small, one[:n]...)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+	assert.Equal(t, expectedContents, string(small))
+
+	_, err = p.Len()
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+}
+
+func TestReaddirnamesContext(t *testing.T) {
+	p := parts.NewParts(testDataPaths, nil)
+	fileNames, err := p.ReaddirnamesContext(context.Background(), 0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	expectedFileNames, err := p.Readdirnames(0)
+	require.NoError(t, err)
+	assert.Equal(t, expectedFileNames, fileNames)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fileNames, err = p.ReaddirnamesContext(ctx, 0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	assert.Equal(t, context.Canceled, err)
+	assert.Empty(t, fileNames)
+}
+
+func TestWalkCollator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"zebra.conf", "éclair.conf", "eclipse.conf"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+
+	p := parts.NewParts([]string{dir}, nil)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			filepath.Join(dir, "eclipse.conf"),
+			filepath.Join(dir, "zebra.conf"),
+			filepath.Join(dir, "éclair.conf"),
+		},
+		fileNames)
+
+	unaccent := strings.NewReplacer("é", "e")
+	p.Config.Collator = func(a, b string) int {
+		return strings.Compare(unaccent.Replace(a), unaccent.Replace(b))
+	}
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			filepath.Join(dir, "éclair.conf"),
+			filepath.Join(dir, "eclipse.conf"),
+			filepath.Join(dir, "zebra.conf"),
+		},
+		fileNames)
+}
+
+func TestWalk(t *testing.T) {
+	p := parts.NewParts(testDataPaths, nil)
+	expectedFileNames, err := p.Readdirnames(0)
+	require.NoError(t, err)
+
+	var fileNames []string
+	err = p.Walk(func(path string, mode parts.FileMode) error {
+		t.Logf("path: %s, mode: %s", path, mode)
+		assert.True(t, mode.IsRegular())
+		fileNames = append(fileNames, path)
+		return nil
+	})
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, expectedFileNames, fileNames)
+
+	stopErr := errors.New("stop")
+	fileNames = nil
+	err = p.Walk(func(path string, mode parts.FileMode) error {
+		fileNames = append(fileNames, path)
+		if len(fileNames) == 2 {
+			return stopErr
+		}
+		return nil
+	})
+	t.Logf("err: %v", err)
+	assert.Equal(t, stopErr, err)
+	assert.Equal(t, expectedFileNames[0:2], fileNames)
+}
+
+func TestWalkNumericSort(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"nodigits.conf", "100-foo.conf", "20-bar.conf", "020-baz.conf", "5-qux.conf"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+
+	config := parts.NewDefaultConfig()
+	config.NumericSort = true
+	p := parts.NewParts([]string{dir}, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			filepath.Join(dir, "5-qux.conf"),
+			filepath.Join(dir, "20-bar.conf"),
+			filepath.Join(dir, "020-baz.conf"),
+			filepath.Join(dir, "100-foo.conf"),
+			filepath.Join(dir, "nodigits.conf"),
+		},
+		fileNames)
+
+	config.Reverse = true
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "nodigits.conf"), fileNames[0])
+	assert.Equal(t, filepath.Join(dir, "5-qux.conf"), fileNames[len(fileNames)-1])
+}
+
+func TestWalkNoFollowSymlinks(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.ModeSymlink,
+		parts.ModePerm,
+		parts.DefaultRegExpFilter)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Empty(t, fileNames)
+
+	config.NoFollowSymlinks = true
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, testDataSymlinks, fileNames)
+
+	// A Config literal follows symbolic links as well.
+	p = parts.NewParts([]string{"testdata/etc"}, &parts.Config{
+		ModeTypeFilter: parts.ModeRegular,
+		ModePermFilter: parts.ModePerm,
+	})
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Contains(t, fileNames, "testdata/etc/30-symlink.conf")
+}
+
+func TestWalkSkipBrokenSymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	goodFile := filepath.Join(dir, "10-good.conf")
+	brokenLink := filepath.Join(dir, "20-broken.conf")
+	require.NoError(t, ioutil.WriteFile(goodFile, []byte("good\n"), 0644))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "notexist.conf"), brokenLink))
+
+	p := parts.NewParts([]string{dir}, nil)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	assert.Error(t, err)
+	assert.Empty(t, fileNames)
+	assert.Empty(t, p.Warnings())
+
+	p.Config.SkipBrokenSymlinks = true
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	t.Logf("warnings: %v", p.Warnings())
+	require.NoError(t, err)
+	assert.Equal(t, []string{goodFile}, fileNames)
+	require.Len(t, p.Warnings(), 1)
+	assert.Contains(t, p.Warnings()[0].Error(), brokenLink)
+}
+
+func TestCount(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	count, err := p.Count()
+	t.Logf("count: %d", count)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, len(testDataConfigFiles), count)
+
+	p = parts.NewParts([]string{"/notexist"}, nil)
+	count, err = p.Count()
+	t.Logf("count: %d", count)
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+	assert.Zero(t, count)
+}
+
+func TestLookup(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	path, ok := p.Lookup("10-both.conf")
+	t.Logf("path: %s, ok: %t", path, ok)
+	assert.True(t, ok)
+	assert.Equal(t, "testdata/etc/10-both.conf", path)
+
+	path, ok = p.Lookup("20-only-lib.conf")
+	t.Logf("path: %s, ok: %t", path, ok)
+	assert.True(t, ok)
+	assert.Equal(t, "testdata/usr/lib/20-only-lib.conf", path)
+
+	path, ok = p.Lookup("40-noconf")
+	t.Logf("path: %s, ok: %t", path, ok)
+	assert.False(t, ok)
+	assert.Empty(t, path)
+}
+
+func TestLookupInfo(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	info, ok, err := p.LookupInfo("20-only-lib.conf")
+	t.Logf("info: %+v, ok: %t, err: %v", info, ok, err)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "20-only-lib.conf", info.Name())
+	assert.EqualValues(t, len("20-only-lib.conf\n"), info.Size())
+	assert.True(t, info.Mode().IsRegular())
+
+	info, ok, err = p.LookupInfo("40-noconf")
+	t.Logf("info: %+v, ok: %t, err: %v", info, ok, err)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	p = parts.NewParts([]string{"testdata/notexist"}, config)
+	info, ok, err = p.LookupInfo("20-only-lib.conf")
+	t.Logf("info: %+v, ok: %t, err: %v", info, ok, err)
+	assert.Error(t, err)
+	assert.False(t, ok)
+}
+
+func TestStat(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	info, err := p.Stat("10-only-etc.conf")
+	t.Logf("info: %+v", info)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, "10-only-etc.conf", info.Name())
+	assert.EqualValues(t, len("10-only-etc.conf\n"), info.Size())
+	assert.True(t, info.Mode().IsRegular())
+
+	// The symlink in etc is followed.
+	info, err = p.Stat("30-symlink.conf")
+	t.Logf("info: %+v", info)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+
+	info, err = p.Stat("40-noconf")
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+}
+
+func TestWalkModifiedWindow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	now := time.Now()
+	oldFile := filepath.Join(dir, "10-old.conf")
+	newFile := filepath.Join(dir, "20-new.conf")
+	futureFile := filepath.Join(dir, "30-future.conf")
+	for path, modTime := range map[string]time.Time{
+		oldFile:    now.Add(-48 * time.Hour),
+		newFile:    now.Add(-1 * time.Hour),
+		futureFile: now.Add(time.Hour),
+	} {
+		require.NoError(t, ioutil.WriteFile(path, []byte(filepath.Base(path)+"\n"), 0644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	config := parts.NewDefaultConfig()
+	config.ModifiedAfter = now.Add(-24 * time.Hour)
+	p := parts.NewParts([]string{dir}, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{newFile, futureFile}, fileNames)
+
+	config.ModifiedBefore = now
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{newFile}, fileNames)
+
+	defer p.Close()
+	b, err := ioutil.ReadAll(p)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, "20-new.conf\n", string(b))
+}
+
+func TestWalkSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	emptyFile := filepath.Join(dir, "10-empty.conf")
+	smallFile := filepath.Join(dir, "20-small.conf")
+	largeFile := filepath.Join(dir, "30-large.conf")
+	require.NoError(t, ioutil.WriteFile(emptyFile, nil, 0644))
+	require.NoError(t, ioutil.WriteFile(smallFile, []byte("1234"), 0644))
+	require.NoError(t, ioutil.WriteFile(largeFile, bytes.Repeat([]byte("x"), 1024), 0644))
+
+	config := parts.NewDefaultConfig()
+	config.MinSize = 4
+	p := parts.NewParts([]string{dir}, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{smallFile, largeFile}, fileNames)
+
+	config.MinSize = 5
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{largeFile}, fileNames)
+
+	config.MinSize = 0
+	config.MaxSize = 1023
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{emptyFile, smallFile}, fileNames)
+}
+
+func TestBytes(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	defer p.Close()
+	expectedContents, err := ioutil.ReadAll(p)
+	require.NoError(t, err)
+
+	opened := 0
+	closed := 0
+	parts.SetOpenFunc(p, func(name string) (io.ReadCloser, error) {
+		if opened == 2 {
+			return nil, errors.New("open failed")
+		}
+		opened++
+		file, err := os.Open(name)
+		return closeCounter{ReadCloser: file, closed: &closed}, err
+	})
+	b, err := p.Bytes()
+	t.Logf("opened: %d, closed: %d", opened, closed)
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+	assert.Nil(t, b)
+	assert.Equal(t, opened, closed)
+
+	parts.SetOpenFunc(p, nil)
+	b, err = p.Bytes()
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, string(expectedContents), string(b))
+}
+
+// closeCounter increments closed when it is closed.
+type closeCounter struct {
+	io.ReadCloser
+	closed *int
+}
+
+func (c closeCounter) Close() error {
+	*c.closed++
+	return c.ReadCloser.Close()
+}
+
+func TestLines(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+
+	lines, err := p.Lines()
+	t.Logf("err: %v", err)
+	t.Logf("lines: %q", lines)
+	require.NoError(t, err)
+	require.Len(t, lines, len(fileNames))
+	for i, fileName := range fileNames {
+		assert.Equal(t, filepath.Base(fileName), lines[i])
+	}
+
+	p = parts.NewParts([]string{"testdata/usr/lib"}, nil)
+	p.Config.RegExpFilter = regexp.MustCompile(`^40-noconf$`)
+	lines, err = p.Lines()
+	t.Logf("err: %v", err)
+	t.Logf("lines: %q", lines)
+	require.NoError(t, err)
+	assert.Empty(t, lines)
+}
+
+func TestReadConcurrent(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	config.ContentCacheBytes = 1024
+
+	p := parts.NewParts(testDataPaths, config)
+	expectedContents, err := p.Bytes()
+	require.NoError(t, err)
+
+	defer p.Close()
+	const numReaders = 8
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	total := 0
+	for i := 0; i < numReaders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 4)
+			for {
+				n, err := p.Read(buf)
+				mu.Lock()
+				total += n
+				mu.Unlock()
+				if err != nil {
+					return
+				}
+			}
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = p.Readdirnames(0)
+			_, _ = p.Bytes()
+			_ = p.Warnings()
+		}()
+	}
+	wg.Wait()
+	t.Logf("total: %d", total)
+	assert.Equal(t, len(expectedContents), total)
+}
+
+func TestWalkExcludeHidden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	visibleFile := filepath.Join(dir, "10-only-etc.conf")
+	hiddenFile := filepath.Join(dir, ".10-only-etc.conf")
+	require.NoError(t, ioutil.WriteFile(visibleFile, nil, 0644))
+	require.NoError(t, ioutil.WriteFile(hiddenFile, nil, 0644))
+
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts([]string{dir}, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{hiddenFile, visibleFile}, fileNames)
+
+	config.ExcludeHidden = true
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{visibleFile}, fileNames)
+}
+
+func TestStandardPaths(t *testing.T) {
+	paths := parts.StandardPaths("foo.d")
+	t.Logf("paths: %s", paths)
+	assert.Equal(t, []string{"/etc/foo.d", "/usr/lib/foo.d"}, paths)
+}
+
+func TestGlob(t *testing.T) {
+	fileNames, err := parts.Glob(testDataPaths, "*-only-*.conf")
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"testdata/etc/10-only-etc.conf",
+			"testdata/usr/lib/10-only-lib.conf",
+			"testdata/etc/20-only-etc.conf",
+			"testdata/usr/lib/20-only-lib.conf",
+			"testdata/test.conf",
+		},
+		fileNames)
+
+	fileNames, err = parts.Glob(testDataPaths[1:], "10-both.conf")
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testdata/etc/10-both.conf"}, fileNames)
+
+	fileNames, err = parts.Glob(testDataPaths, "[-")
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+	assert.Empty(t, fileNames)
+}
+
+func TestReadDirs(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	expected, err := parts.NewParts(testDataPaths, config).Bytes()
+	require.NoError(t, err)
+	contents, err := parts.ReadDirs(testDataPaths, config)
+	t.Logf("contents: %q", contents)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, expected, contents)
+
+	expected, err = parts.NewParts(testDataPaths, nil).Bytes()
+	require.NoError(t, err)
+	contents, err = parts.ReadDirs(testDataPaths, nil)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, expected, contents)
+
+	contents, err = parts.ReadDirs([]string{"testdata/nonexistent"}, config)
+	t.Logf("err: %v", err)
+	assert.True(t, errors.Is(err, parts.ErrPathNotFound))
+	assert.Contains(t, err.Error(), "testdata/nonexistent")
+	assert.Nil(t, contents)
+}
+
+func TestExpandPaths(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+	require.NoError(t, os.Setenv("PARTS_TEST_DIR", "/opt/app"))
+	defer os.Unsetenv("PARTS_TEST_DIR")
+
+	paths, err := parts.ExpandPaths([]string{
+		"$PARTS_TEST_DIR/conf.d",
+		"${PARTS_TEST_DIR}/override.d",
+		"~",
+		"~/overrides",
+		"~user/overrides",
+		"/etc/$PARTS_TEST_NOTSET/foo.d",
+		"/usr/lib/foo.d",
+	})
+	t.Logf("paths: %s", paths)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"/opt/app/conf.d",
+			"/opt/app/override.d",
+			home,
+			filepath.Join(home, "overrides"),
+			"~user/overrides",
+			"/etc//foo.d",
+			"/usr/lib/foo.d",
+		},
+		paths)
+}
+
+func TestValidate(t *testing.T) {
+	p := parts.NewParts(testDataPaths, nil)
+	err := p.Validate()
+	t.Logf("err: %v", err)
+	assert.NoError(t, err)
+
+	p = parts.NewParts(nil, nil)
+	err = p.Validate()
+	t.Logf("err: %v", err)
+	assert.True(t, errors.Is(err, parts.ErrNoPaths))
+
+	paths := []string{"testdata/etc", "testdata/nonexistent", "/dev/null"}
+	p = parts.NewParts(paths, nil)
+	err = p.Validate()
+	t.Logf("err: %v", err)
+	assert.True(t, errors.Is(err, parts.ErrPathNotFound))
+
+	config := parts.NewDefaultConfig()
+	config.IgnoreMissingDirs = true
+	p = parts.NewParts(paths, config)
+	err = p.Validate()
+	t.Logf("err: %v", err)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "/dev/null: not a directory or regular file")
+
+	config = parts.NewDefaultConfig()
+	config.ContinueOnError = true
+	p = parts.NewParts(paths, config)
+	err = p.Validate()
+	t.Logf("err: %v", err)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 paths are invalid")
+	assert.True(t, errors.Is(err, parts.ErrPathNotFound))
+	var pathErr *os.PathError
+	require.True(t, errors.As(err, &pathErr))
+	assert.Equal(t, "testdata/nonexistent", pathErr.Path)
+	assert.Contains(t, err.Error(), "testdata/nonexistent")
+	assert.Contains(t, err.Error(), "/dev/null")
+}
+
+func TestWalkLessFunc(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	config.LessFunc = func(a, b string) bool {
+		return a < b
+	}
+
+	p := parts.NewParts(testDataPaths, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, testDataConfigFiles, fileNames)
+
+	config.Reverse = true
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	reversedTestDataConfigFiles := make([]string, len(testDataConfigFiles))
+	copy(reversedTestDataConfigFiles, testDataConfigFiles)
+	sort.Sort(sort.Reverse(sort.StringSlice(reversedTestDataConfigFiles)))
+	assert.Equal(t, reversedTestDataConfigFiles, fileNames)
+}
+
+func TestWalkRecursive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	subDir := filepath.Join(dir, "sub")
+	deeperDir := filepath.Join(subDir, "deeper")
+	require.NoError(t, os.MkdirAll(deeperDir, 0755))
+	for _, path := range []string{
+		filepath.Join(dir, "10-a.conf"),
+		filepath.Join(subDir, "10-a.conf"),
+		filepath.Join(subDir, "20-b.conf"),
+		filepath.Join(deeperDir, "30-c.conf"),
+	} {
+		require.NoError(t, ioutil.WriteFile(path, nil, 0644))
+	}
+
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts([]string{dir}, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "10-a.conf")}, fileNames)
+
+	config.Recursive = true
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			filepath.Join(dir, "10-a.conf"),
+			filepath.Join(subDir, "20-b.conf"),
+			filepath.Join(deeperDir, "30-c.conf"),
+		},
+		fileNames)
+
+	// FindFirst and Lookup find files in subdirectories alike.
+	path, err := p.FindFirst("30-c.conf")
+	t.Logf("path: %s, err: %v", path, err)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(deeperDir, "30-c.conf"), path)
+	lookupPath, ok := p.Lookup("30-c.conf")
+	assert.True(t, ok)
+	assert.Equal(t, path, lookupPath)
+	path, err = p.FindFirst("40-none.conf")
+	t.Logf("path: %s, err: %v", path, err)
+	assert.True(t, errors.Is(err, parts.ErrNoMatch))
+
+	config.MaxDepth = 1
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			filepath.Join(dir, "10-a.conf"),
+			filepath.Join(subDir, "20-b.conf"),
+		},
+		fileNames)
+}
+
+func TestResolve(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	paths, err := p.Resolve()
+	t.Logf("paths: %v", paths)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{"testdata/etc/10-both.conf", "testdata/usr/lib/10-both.conf"},
+		paths["10-both.conf"])
+	assert.Equal(t, []string{"testdata/usr/lib/10-only-lib.conf"}, paths["10-only-lib.conf"])
+	assert.Equal(t, []string{"testdata/test.conf"}, paths["test.conf"])
+	assert.NotContains(t, paths, "40-noconf")
+
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Len(t, paths, len(fileNames))
+	for _, fileName := range fileNames {
+		assert.Equal(t, fileName, paths[filepath.Base(fileName)][0])
+	}
+}
+
+func TestWalkCleanPaths(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts([]string{"testdata/etc/", "testdata/usr/../usr/lib", "testdata/etc/../test.conf"}, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"testdata/etc/10-both.conf",
+			"testdata/etc/10-only-etc.conf",
+			"testdata/usr/lib/10-only-lib.conf",
+			"testdata/etc/20-only-etc.conf",
+			"testdata/usr/lib/20-only-lib.conf",
+			"testdata/etc/30-symlink.conf",
+			"testdata/usr/lib/nodigits.conf",
+			"testdata/test.conf",
+		},
+		fileNames)
+}
+
+func TestWalkAbsolutePaths(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	relFileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	config.AbsolutePaths = true
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	require.Len(t, fileNames, len(relFileNames))
+	for i, fileName := range fileNames {
+		expected, err := filepath.Abs(relFileNames[i])
+		require.NoError(t, err)
+		assert.Equal(t, expected, fileName)
+	}
+
+	path, ok := p.Lookup("10-both.conf")
+	t.Logf("path: %s", path)
+	assert.True(t, ok)
+	assert.True(t, filepath.IsAbs(path))
+
+	paths, err := p.Resolve()
+	t.Logf("paths: %v", paths)
+	require.NoError(t, err)
+	for _, path := range paths["10-both.conf"] {
+		assert.True(t, filepath.IsAbs(path))
+	}
+
+	b, err := ioutil.ReadAll(p)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.NoError(t, p.Close())
+	assert.NotEmpty(t, b)
+}
+
+func TestResolvedMap(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	paths, err := p.ResolvedMap()
+	t.Logf("paths: %v", paths)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, "testdata/etc/10-both.conf", paths["10-both.conf"])
+	assert.Equal(t, "testdata/test.conf", paths["test.conf"])
+	assert.NotContains(t, paths, "40-noconf")
+
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Len(t, paths, len(fileNames))
+	for _, fileName := range fileNames {
+		assert.Equal(t, fileName, paths[filepath.Base(fileName)])
+		path, ok := p.Lookup(filepath.Base(fileName))
+		assert.True(t, ok)
+		assert.Equal(t, path, paths[filepath.Base(fileName)])
+	}
+
+	p = parts.NewParts(nil, config)
+	paths, err = p.ResolvedMap()
+	t.Logf("err: %v", err)
+	assert.True(t, errors.Is(err, parts.ErrNoPaths))
+	assert.Nil(t, paths)
+}
+
+func TestWalkGlob(t *testing.T) {
+	config := parts.NewDefaultConfig()
+	config.GlobFilter = "*.conf"
+	t.Logf("config: %v", config)
+
+	p := parts.NewParts([]string{"testdata/usr/lib"}, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"testdata/usr/lib/10-both.conf",
+			"testdata/usr/lib/10-only-lib.conf",
+			"testdata/usr/lib/20-only-lib.conf",
+			"testdata/usr/lib/30-symlink.conf",
+			"testdata/usr/lib/nodigits.conf",
+		},
+		fileNames)
+
+	// Both the glob and the regexp must match.
+	config.RegExpFilter = regexp.MustCompile(`^[0-9]`)
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"testdata/usr/lib/10-both.conf",
+			"testdata/usr/lib/10-only-lib.conf",
+			"testdata/usr/lib/20-only-lib.conf",
+			"testdata/usr/lib/30-symlink.conf",
+		},
+		fileNames)
+
+	config.GlobFilter = "[-"
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	assert.Error(t, err)
+	assert.Empty(t, fileNames)
+}
+
+func TestWalkRegExpMatchesFullPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"enabled/10-foo", "enabled/20-bar", "disabled/30-baz"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, nil, 0644))
+	}
+
+	config := parts.NewDefaultConfig()
+	config.Recursive = true
+	config.RegExpFilter = regexp.MustCompile(`/enabled/`)
+	p := parts.NewParts([]string{dir}, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Empty(t, fileNames)
+
+	config.RegExpMatchesFullPath = true
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			filepath.Join(dir, "enabled/10-foo"),
+			filepath.Join(dir, "enabled/20-bar"),
+		},
+		fileNames)
+}
+
+func TestWalkExtensions(t *testing.T) {
+	config := parts.NewDefaultConfig()
+	config.Extensions = []string{".sh", "conf"}
+	t.Logf("config: %v", config)
+
+	p := parts.NewParts([]string{"testdata/usr/lib"}, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"testdata/usr/lib/10-both.conf",
+			"testdata/usr/lib/10-executable.sh",
+			"testdata/usr/lib/10-only-lib.conf",
+			"testdata/usr/lib/20-only-lib.conf",
+			"testdata/usr/lib/30-symlink.conf",
+			"testdata/usr/lib/nodigits.conf",
+		},
+		fileNames)
+
+	// Both the extensions and the regexp must match.
+	config.RegExpFilter = regexp.MustCompile(`^[0-9]`)
+	config.Extensions = []string{".sh"}
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testdata/usr/lib/10-executable.sh"}, fileNames)
+}
+
+func TestWalkOwner(t *testing.T) {
+	uid, gid := os.Getuid(), os.Getgid()
+	if uid < 0 {
+		t.Skip("file ownership is not supported")
+	}
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "10-owned.conf")
+	require.NoError(t, ioutil.WriteFile(file, nil, 0644))
+
+	// The zero value does not filter by owner, even though the
+	// ids are 0.
+	p := parts.NewParts([]string{dir}, &parts.Config{
+		ModeTypeFilter: parts.DefaultModeTypeFilter,
+		ModePermFilter: parts.DefaultModePermFilter,
+	})
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{file}, fileNames)
+
+	config := parts.NewDefaultConfig()
+	assert.False(t, config.FilterOwner)
+	assert.False(t, config.FilterGroup)
+	config.OwnerUID = uid
+	config.OwnerGID = gid
+	config.FilterOwner = true
+	config.FilterGroup = true
+	p = parts.NewParts([]string{dir}, config)
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{file}, fileNames)
+
+	config.OwnerUID = uid + 1
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Empty(t, fileNames)
+
+	config.FilterOwner = false
+	config.OwnerGID = gid + 1
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Empty(t, fileNames)
+}
+
+func TestWalkRejectWorldWritable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	safeFile := filepath.Join(dir, "10-safe.conf")
+	writableFile := filepath.Join(dir, "20-writable.conf")
+	require.NoError(t, ioutil.WriteFile(safeFile, nil, 0644))
+	require.NoError(t, ioutil.WriteFile(writableFile, nil, 0644))
+	require.NoError(t, os.Chmod(writableFile, 0666))
+
+	config := parts.NewDefaultConfig()
+	p := parts.NewParts([]string{dir}, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{safeFile, writableFile}, fileNames)
+	assert.Empty(t, p.Warnings())
+
+	config.RejectWorldWritable = true
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{safeFile}, fileNames)
+	warnings := p.Warnings()
+	t.Logf("warnings: %v", warnings)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0].Error(), writableFile)
+}
+
+func TestWalkRejectSetuid(t *testing.T) {
+	fsys := fstest.MapFS{
+		"etc/10-plain":  &fstest.MapFile{Mode: 0755},
+		"etc/20-setuid": &fstest.MapFile{Mode: os.ModeSetuid | 0755},
+		"etc/30-setgid": &fstest.MapFile{Mode: os.ModeSetgid | 0755},
+	}
+	config := parts.NewDefaultConfig()
+	p := parts.NewPartsFS(fsys, []string{"etc"}, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"etc/10-plain", "etc/20-setuid", "etc/30-setgid"}, fileNames)
+	assert.Empty(t, p.Warnings())
+
+	config.RejectSetuid = true
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"etc/10-plain"}, fileNames)
+	warnings := p.Warnings()
+	t.Logf("warnings: %v", warnings)
+	require.Len(t, warnings, 2)
+	assert.Contains(t, warnings[0].Error(), "etc/20-setuid")
+	assert.Contains(t, warnings[1].Error(), "etc/30-setgid")
+}
+
+func TestSeek(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	defer p.Close()
+	var _ io.ReadSeeker = p
+
+	first, err := ioutil.ReadAll(p)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	require.True(t, len(first) > 5)
+
+	offset, err := p.Seek(0, io.SeekStart)
+	t.Logf("offset: %d, err: %v", offset, err)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), offset)
+	second, err := ioutil.ReadAll(p)
+	require.NoError(t, err)
+	assert.Equal(t, string(first), string(second))
+
+	offset, err = p.Seek(5, io.SeekStart)
+	t.Logf("offset: %d, err: %v", offset, err)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), offset)
+	rest, err := ioutil.ReadAll(p)
+	require.NoError(t, err)
+	assert.Equal(t, string(first[5:]), string(rest))
+
+	offset, err = p.Seek(int64(len(first))+10, io.SeekStart)
+	t.Logf("offset: %d, err: %v", offset, err)
+	require.NoError(t, err)
+	rest, err = ioutil.ReadAll(p)
+	require.NoError(t, err)
+	assert.Empty(t, rest)
+
+	_, err = p.Seek(0, io.SeekCurrent)
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+	_, err = p.Seek(0, io.SeekEnd)
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+	_, err = p.Seek(-1, io.SeekStart)
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+}
+
+func TestLen(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	b, err := p.Bytes()
+	require.NoError(t, err)
+	n, err := p.Len()
+	t.Logf("n: %d, err: %v", n, err)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(b)), n)
+
+	config.Separator = []byte("\n---\n")
+	b, err = p.Bytes()
+	require.NoError(t, err)
+	n, err = p.Len()
+	t.Logf("n: %d, err: %v", n, err)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(b)), n)
+
+	config.TrimTrailingWhitespace = true
+	n, err = p.Len()
+	t.Logf("n: %d, err: %v", n, err)
+	assert.Error(t, err)
+}
+
+func TestReadFileHeader(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	config.Separator = []byte("--\n")
+	config.FileHeaderFunc = func(path string) []byte {
+		return []byte("# " + path + "\n")
+	}
+
+	p := parts.NewParts(testDataPaths, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+
+	expectedContents := ""
+	for i, fileName := range fileNames {
+		if i > 0 {
+			expectedContents += "--\n"
+		}
+		expectedContents += "# " + fileName + "\n"
+		expectedContents += filepath.Base(fileName) + "\n"
+	}
+
+	defer p.Close()
+	b, err := ioutil.ReadAll(p)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	t.Logf("expectedContents:\n%s", expectedContents)
+	t.Logf("contents:\n%s", string(b))
+	assert.EqualValues(t, expectedContents, string(b))
+
+	// WriteTo continues a read in progress, so start over.
+	require.NoError(t, p.Close())
+	var buf bytes.Buffer
+	n, err := p.WriteTo(&buf)
+	t.Logf("n: %d", n)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.EqualValues(t, len(expectedContents), n)
+	assert.EqualValues(t, expectedContents, buf.String())
+
+	length, err := p.Len()
+	t.Logf("length: %d, err: %v", length, err)
+	require.NoError(t, err)
+	assert.EqualValues(t, len(expectedContents), length)
+}
+
+func TestReadDecompress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	plainFile := filepath.Join(dir, "10-plain.conf")
+	gzipFile := filepath.Join(dir, "20-big.conf.gz")
+	require.NoError(t, ioutil.WriteFile(plainFile, []byte("plain\n"), 0644))
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err = zw.Write([]byte("compressed\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.NoError(t, ioutil.WriteFile(gzipFile, buf.Bytes(), 0644))
+
+	config := parts.NewDefaultConfig()
+	p := parts.NewParts([]string{dir}, config)
+	b, err := p.Bytes()
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, append([]byte("plain\n"), buf.Bytes()...), b)
+
+	config.Decompress = true
+	b, err = p.Bytes()
+	t.Logf("err: %v", err)
+	t.Logf("contents:\n%s", string(b))
+	require.NoError(t, err)
+	assert.Equal(t, "plain\ncompressed\n", string(b))
+
+	opened := 0
+	closed := 0
+	parts.SetOpenFunc(p, func(name string) (io.ReadCloser, error) {
+		opened++
+		file, err := os.Open(name)
+		return closeCounter{ReadCloser: file, closed: &closed}, err
+	})
+	b, err = ioutil.ReadAll(p)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, "plain\ncompressed\n", string(b))
+	require.NoError(t, p.Close())
+	t.Logf("opened: %d, closed: %d", opened, closed)
+	assert.Equal(t, 2, opened)
+	assert.Equal(t, opened, closed)
+
+	// A .gz file that is not gzip compressed is an error.
+	require.NoError(t, ioutil.WriteFile(gzipFile, []byte("not compressed\n"), 0644))
+	b, err = p.Bytes()
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+	assert.Nil(t, b)
+}
+
+func TestBasenames(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	names, err := p.Basenames(0)
+	t.Logf("err: %v", err)
+	t.Logf("names: %s", names)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"10-both.conf",
+			"10-only-etc.conf",
+			"10-only-lib.conf",
+			"20-only-etc.conf",
+			"20-only-lib.conf",
+			"30-symlink.conf",
+			"nodigits.conf",
+			"test.conf",
+		},
+		names)
+
+	config.Reverse = true
+	names, err = p.Basenames(2)
+	t.Logf("err: %v", err)
+	t.Logf("names: %s", names)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"test.conf", "nodigits.conf"}, names)
+}
+
+func TestReaddirnamesRange(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	all, err := p.Readdirnames(0)
+	t.Logf("all: %s", all)
+	require.NoError(t, err)
+	require.Len(t, all, 8)
+
+	for _, test := range []struct {
+		offset, limit int
+		expected      []string
+	}{
+		{0, 0, all},
+		{0, 3, all[:3]},
+		{3, 3, all[3:6]},
+		{6, 3, all[6:]},
+		{2, 0, all[2:]},
+		{-1, 2, all[:2]},
+		{8, 3, []string{}},
+		{100, 3, []string{}},
+	} {
+		names, err := p.ReaddirnamesRange(test.offset, test.limit)
+		t.Logf("offset: %d, limit: %d, names: %s", test.offset, test.limit, names)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, names)
+	}
+
+	config.Reverse = true
+	names, err := p.ReaddirnamesRange(1, 2)
+	t.Logf("err: %v", err)
+	t.Logf("names: %s", names)
+	require.NoError(t, err)
+	assert.Equal(t, []string{all[6], all[5]}, names)
+
+	p = parts.NewParts([]string{"testdata/nonexistent"}, config)
+	names, err = p.ReaddirnamesRange(0, 2)
+	t.Logf("err: %v", err)
+	assert.True(t, errors.Is(err, parts.ErrPathNotFound))
+	assert.Empty(t, names)
+}
+
+func TestWalkContinueOnError(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	paths := []string{"testdata/notexist", "testdata/usr/lib"}
+	p := parts.NewParts(paths, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	assert.Error(t, err)
+	assert.Empty(t, fileNames)
+
+	config.ContinueOnError = true
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"testdata/usr/lib/10-both.conf",
+			"testdata/usr/lib/10-only-lib.conf",
+			"testdata/usr/lib/20-only-lib.conf",
+			"testdata/usr/lib/30-symlink.conf",
+			"testdata/usr/lib/nodigits.conf",
+		},
+		fileNames)
+	warnings := p.Warnings()
+	t.Logf("warnings: %v", warnings)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0].Error(), "testdata/notexist")
+}
+
+func TestWalkIgnoreMissingDirs(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	config.IgnoreMissingDirs = true
+
+	paths := []string{"testdata/notexist", "testdata/usr/lib"}
+	p := parts.NewParts(paths, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"testdata/usr/lib/10-both.conf",
+			"testdata/usr/lib/10-only-lib.conf",
+			"testdata/usr/lib/20-only-lib.conf",
+			"testdata/usr/lib/30-symlink.conf",
+			"testdata/usr/lib/nodigits.conf",
+		},
+		fileNames)
+	assert.Empty(t, p.Warnings())
+
+	path, err := p.FindFirst("10-both.conf")
+	t.Logf("path: %s", path)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, "testdata/usr/lib/10-both.conf", path)
+
+	// Other errors are still returned.
+	paths = []string{"testdata/test.conf/notexist", "testdata/usr/lib"}
+	p = parts.NewParts(paths, config)
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	assert.Error(t, err)
+	assert.Empty(t, fileNames)
+}
+
+func TestWalkIncludeDirs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "10-file.conf")
+	subDir := filepath.Join(dir, "20-dir.conf")
+	excludedDir := filepath.Join(dir, "30-dir.disabled")
+	require.NoError(t, ioutil.WriteFile(file, nil, 0644))
+	require.NoError(t, os.Mkdir(subDir, 0755))
+	require.NoError(t, os.Mkdir(excludedDir, 0755))
+
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts([]string{dir}, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{file}, fileNames)
+
+	config.IncludeDirs = true
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{file, subDir}, fileNames)
+}
+
+func TestNewPartsMulti(t *testing.T) {
+	confConfig, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	require.NoError(t, err)
+	execConfig, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`(\.conf|\.sh)$`)
+	require.NoError(t, err)
+
+	p := parts.NewPartsMulti([]parts.PathConfig{
+		{Path: "testdata/test.conf"},
+		{Path: "testdata/etc", Config: confConfig},
+		{Path: "testdata/usr/lib", Config: execConfig},
+	})
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"testdata/etc/10-both.conf",
+			"testdata/usr/lib/10-executable.sh",
+			"testdata/etc/10-only-etc.conf",
+			"testdata/usr/lib/10-only-lib.conf",
+			"testdata/etc/20-only-etc.conf",
+			"testdata/usr/lib/20-only-lib.conf",
+			"testdata/etc/30-symlink.conf",
+			"testdata/usr/lib/nodigits.conf",
+			"testdata/test.conf",
+		},
+		fileNames)
+
+	// The base configuration controls the ordering.
+	p.Config.Reverse = true
+	fileNames, err = p.Readdirnames(2)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{"testdata/test.conf", "testdata/usr/lib/nodigits.conf"},
+		fileNames)
+}
+
+func TestWalkSortByFullPath(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	config.KeepDuplicates = true
+	config.SortByFullPath = true
+
+	p := parts.NewParts(testDataPaths, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	expectedFileNames := []string{
+		"testdata/etc/10-both.conf",
+		"testdata/etc/10-only-etc.conf",
+		"testdata/etc/20-only-etc.conf",
+		"testdata/etc/30-symlink.conf",
+		"testdata/test.conf",
+		"testdata/usr/lib/10-both.conf",
+		"testdata/usr/lib/10-only-lib.conf",
+		"testdata/usr/lib/20-only-lib.conf",
+		"testdata/usr/lib/30-symlink.conf",
+		"testdata/usr/lib/nodigits.conf",
+	}
+	assert.Equal(t, expectedFileNames, fileNames)
+
+	config.Reverse = true
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	for i, j := 0, len(expectedFileNames)-1; i < j; i, j = i+1, j-1 {
+		expectedFileNames[i], expectedFileNames[j] = expectedFileNames[j], expectedFileNames[i]
+	}
+	assert.Equal(t, expectedFileNames, fileNames)
+}
+
+func TestWalkSortBy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	now := time.Now()
+	for _, file := range []struct {
+		name    string
+		size    int
+		modTime time.Time
+	}{
+		{"10-new", 2, now},
+		{"20-old", 3, now.Add(-2 * time.Hour)},
+		{"30-middle", 1, now.Add(-time.Hour)},
+		{"40-middle", 1, now.Add(-time.Hour)},
+	} {
+		path := filepath.Join(dir, file.name)
+		require.NoError(t, ioutil.WriteFile(path, make([]byte, file.size), 0644))
+		require.NoError(t, os.Chtimes(path, file.modTime, file.modTime))
+	}
+
+	config := parts.NewDefaultConfig()
+	p := parts.NewParts([]string{dir}, config)
+	for _, test := range []struct {
+		sortBy   parts.SortKey
+		reverse  bool
+		expected []string
+	}{
+		{parts.SortByName, false, []string{"10-new", "20-old", "30-middle", "40-middle"}},
+		{parts.SortByModTime, false, []string{"20-old", "30-middle", "40-middle", "10-new"}},
+		{parts.SortByModTime, true, []string{"10-new", "40-middle", "30-middle", "20-old"}},
+		{parts.SortBySize, false, []string{"30-middle", "40-middle", "10-new", "20-old"}},
+		{parts.SortBySize, true, []string{"20-old", "10-new", "40-middle", "30-middle"}},
+	} {
+		config.SortBy = test.sortBy
+		config.Reverse = test.reverse
+		fileNames, err := p.Basenames(0)
+		t.Logf("sortBy: %d, reverse: %t", test.sortBy, test.reverse)
+		t.Logf("err: %v", err)
+		t.Logf("fileNames: %s", fileNames)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, fileNames)
+	}
+}
+
+func TestWalkCaseInsensitiveSort(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"apache.conf", "Apache.conf", "Zope.conf", "nginx.conf"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+
+	config := parts.NewDefaultConfig()
+	p := parts.NewParts([]string{dir}, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			filepath.Join(dir, "Apache.conf"),
+			filepath.Join(dir, "Zope.conf"),
+			filepath.Join(dir, "apache.conf"),
+			filepath.Join(dir, "nginx.conf"),
+		},
+		fileNames)
+
+	config.CaseInsensitiveSort = true
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			filepath.Join(dir, "Apache.conf"),
+			filepath.Join(dir, "apache.conf"),
+			filepath.Join(dir, "nginx.conf"),
+			filepath.Join(dir, "Zope.conf"),
+		},
+		fileNames)
+
+	config.Reverse = true
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			filepath.Join(dir, "Zope.conf"),
+			filepath.Join(dir, "nginx.conf"),
+			filepath.Join(dir, "apache.conf"),
+			filepath.Join(dir, "Apache.conf"),
+		},
+		fileNames)
+}
+
+func TestOpen(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	expectedContents, err := p.Bytes()
+	require.NoError(t, err)
+
+	first, err := p.Open()
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	second, err := p.Open()
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	// The readers are independent of each other and of Read.
+	b := make([]byte, 5)
+	_, err = io.ReadFull(first, b)
+	require.NoError(t, err)
+	assert.Equal(t, string(expectedContents[:5]), string(b))
+	contents, err := ioutil.ReadAll(second)
+	require.NoError(t, err)
+	assert.Equal(t, string(expectedContents), string(contents))
+	contents, err = ioutil.ReadAll(p)
+	require.NoError(t, err)
+	assert.Equal(t, string(expectedContents), string(contents))
+	require.NoError(t, p.Close())
+	contents, err = ioutil.ReadAll(first)
+	require.NoError(t, err)
+	assert.Equal(t, string(expectedContents[5:]), string(contents))
+
+	assert.NoError(t, first.Close())
+	assert.NoError(t, second.Close())
+
+	opened := 0
+	closed := 0
+	parts.SetOpenFunc(p, func(name string) (io.ReadCloser, error) {
+		if opened == 2 {
+			return nil, errors.New("open failed")
+		}
+		opened++
+		file, err := os.Open(name)
+		return closeCounter{ReadCloser: file, closed: &closed}, err
+	})
+	reader, err := p.Open()
+	t.Logf("opened: %d, closed: %d", opened, closed)
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+	assert.Nil(t, reader)
+	assert.Equal(t, opened, closed)
+}
+
+func TestCloseError(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	failures := 1
+	parts.SetOpenFunc(p, func(name string) (io.ReadCloser, error) {
+		file, err := os.Open(name)
+		if err != nil || failures == 0 {
+			return file, err
+		}
+		failures--
+		return failingCloser{ReadCloser: file, name: name}, nil
+	})
+
+	// The first close error is not dropped.
+	_, err = ioutil.ReadAll(p)
+	require.NoError(t, err)
+	err = p.Close()
+	t.Logf("err: %v", err)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, errCloseFailed))
+
+	// All close errors are reported.
+	failures = 2
+	_, err = ioutil.ReadAll(p)
+	require.NoError(t, err)
+	err = p.Close()
+	t.Logf("err: %v", err)
+	require.Error(t, err)
+	assert.Equal(t, 2, strings.Count(err.Error(), "close failed"))
+	assert.True(t, errors.Is(err, errCloseFailed))
+	var pathErr *os.PathError
+	require.True(t, errors.As(err, &pathErr))
+	assert.Equal(t, "close", pathErr.Op)
+
+	_, err = ioutil.ReadAll(p)
+	require.NoError(t, err)
+	assert.NoError(t, p.Close())
+}
+
+// errCloseFailed is returned by failingCloser.
+var errCloseFailed = errors.New("close failed")
+
+// failingCloser closes the underlying file but always returns an
+// error.
+type failingCloser struct {
+	io.ReadCloser
+	name string
+}
+
+func (f failingCloser) Close() error {
+	_ = f.ReadCloser.Close()
+	return &os.PathError{Op: "close", Path: f.name, Err: errCloseFailed}
+}
+
+func TestOpenFiles(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	p := parts.NewParts(testDataPaths, config)
+	defer p.Close()
+	assert.Empty(t, p.OpenFiles())
+
+	fileNames, err := p.Readdirnames(0)
+	require.NoError(t, err)
+	b := make([]byte, 1)
+	_, err = p.Read(b)
+	require.NoError(t, err)
+	openFiles := p.OpenFiles()
+	t.Logf("openFiles: %s", openFiles)
+	assert.Equal(t, fileNames, openFiles)
+
+	require.NoError(t, p.Close())
+	assert.Empty(t, p.OpenFiles())
+}
+
+func TestPeek(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	first := filepath.Join(dir, "10-first")
+	second := filepath.Join(dir, "20-second")
+	require.NoError(t, ioutil.WriteFile(first, []byte("aaa"), 0644))
+	require.NoError(t, ioutil.WriteFile(second, []byte("bb"), 0644))
+
+	config := parts.NewDefaultConfig()
+	config.Separator = []byte("\n")
+	p := parts.NewParts([]string{dir}, config)
+	defer p.Close()
+	path, ok := p.Peek()
+	assert.False(t, ok)
+	assert.Empty(t, path)
+
+	// Peek moves on to the next file as soon as the last byte of
+	// a file has been read, with the separator belonging to the
+	// file following it.
+	var contents []byte
+	var paths []string
+	b := make([]byte, 1)
+	for {
+		n, err := p.Read(b)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		contents = append(contents, b[:n]...)
+		path, _ := p.Peek()
+		paths = append(paths, path)
+	}
+	t.Logf("contents: %q", contents)
+	t.Logf("paths: %s", paths)
+	assert.Equal(t, "aaa\nbb", string(contents))
+	assert.Equal(t, []string{first, first, second, second, second, ""}, paths)
+
+	path, ok = p.Peek()
+	assert.False(t, ok)
+	assert.Empty(t, path)
+
+	// Reading exactly the contents of the first file.
+	require.NoError(t, p.Close())
+	b = make([]byte, 3)
+	n, err := p.Read(b)
+	t.Logf("n: %d, err: %v", n, err)
+	require.NoError(t, err)
+	require.Equal(t, "aaa", string(b[:n]))
+	path, ok = p.Peek()
+	assert.True(t, ok)
+	assert.Equal(t, second, path)
+
+	// The byte read ahead is not lost.
+	rest, err := ioutil.ReadAll(p)
+	require.NoError(t, err)
+	assert.Equal(t, "\nbb", string(rest))
+}
+
+func TestReadOnFileStart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	first := filepath.Join(dir, "10-first")
+	empty := filepath.Join(dir, "20-empty")
+	third := filepath.Join(dir, "30-third")
+	require.NoError(t, ioutil.WriteFile(first, []byte("aaa"), 0644))
+	require.NoError(t, ioutil.WriteFile(empty, nil, 0644))
+	require.NoError(t, ioutil.WriteFile(third, []byte("ccc"), 0644))
+
+	var buf bytes.Buffer
+	var starts []string
+	var offsets []int
+	config := parts.NewDefaultConfig()
+	config.OnFileStart = func(path string) {
+		starts = append(starts, path)
+		offsets = append(offsets, buf.Len())
+	}
+	p := parts.NewParts([]string{dir}, config)
+	defer p.Close()
+	b := make([]byte, 2)
+	for {
+		n, err := p.Read(b)
+		buf.Write(b[:n])
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+	t.Logf("contents: %q", buf.String())
+	t.Logf("starts: %s", starts)
+	t.Logf("offsets: %v", offsets)
+	assert.Equal(t, "aaaccc", buf.String())
+	assert.Equal(t, []string{first, third}, starts)
+	assert.Equal(t, []int{0, 3}, offsets)
+}
+
+func TestReadAll(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	config.Separator = []byte("\n")
+
+	p := parts.NewParts(testDataPaths, config)
+	expected, err := p.Bytes()
+	require.NoError(t, err)
+
+	// A read in progress is abandoned and its files are closed.
+	b := make([]byte, 1)
+	_, err = p.Read(b)
+	require.NoError(t, err)
+	require.NotEmpty(t, p.OpenFiles())
+
+	contents, err := p.ReadAll()
+	t.Logf("contents: %q", contents)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, expected, contents)
+	assert.Empty(t, p.OpenFiles())
+
+	p = parts.NewParts([]string{"testdata/nonexistent"}, config)
+	contents, err = p.ReadAll()
+	t.Logf("err: %v", err)
+	assert.True(t, errors.Is(err, parts.ErrPathNotFound))
+	assert.Nil(t, contents)
+}
+
+func TestWalkLastWins(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	config.LastWins = true
+
+	p := parts.NewParts(testDataPaths, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"testdata/usr/lib/10-both.conf",
+			"testdata/etc/10-only-etc.conf",
+			"testdata/usr/lib/10-only-lib.conf",
+			"testdata/etc/20-only-etc.conf",
+			"testdata/usr/lib/20-only-lib.conf",
+			"testdata/usr/lib/30-symlink.conf",
+			"testdata/usr/lib/nodigits.conf",
+			"testdata/test.conf",
+		},
+		fileNames)
+
+	path, err := p.FindFirst("10-both.conf")
+	t.Logf("path: %s", path)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, "testdata/usr/lib/10-both.conf", path)
+
+	paths, err := p.Resolve()
+	t.Logf("paths: %v", paths)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{"testdata/usr/lib/10-both.conf", "testdata/etc/10-both.conf"},
+		paths["10-both.conf"])
+}
+
+func TestWalkRegularOrSymlinkToRegular(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "10-regular"), nil, 0644))
+	require.NoError(t, os.Symlink("10-regular", filepath.Join(dir, "20-link-regular")))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "30-dir"), 0755))
+	require.NoError(t, os.Symlink("30-dir", filepath.Join(dir, "40-link-dir")))
+	require.NoError(t, os.Symlink("nonexistent", filepath.Join(dir, "50-link-broken")))
+
+	config := parts.NewDefaultConfig()
+	config.NoFollowSymlinks = true
+	config.ModeTypeFilter = parts.FileMode(parts.ModeRegular) | parts.ModeSymlink
+	p := parts.NewParts([]string{dir}, config)
+	fileNames, err := p.Basenames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10-regular", "20-link-regular", "40-link-dir", "50-link-broken"}, fileNames)
+
+	config.RegularOrSymlinkToRegular = true
+	fileNames, err = p.Basenames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10-regular", "20-link-regular"}, fileNames)
+
+	// Broken symbolic links are excluded when following them too.
+	config = parts.NewDefaultConfig()
+	config.RegularOrSymlinkToRegular = true
+	p = parts.NewParts([]string{dir}, config)
+	fileNames, err = p.Basenames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10-regular", "20-link-regular"}, fileNames)
+	assert.Empty(t, p.Warnings())
+
+	report, err := p.Explain()
+	require.NoError(t, err)
+	t.Logf("excluded: %v", report.Excluded)
+	assert.Contains(t, report.Excluded, parts.Exclusion{
+		Path:   filepath.Join(dir, "50-link-broken"),
+		Reason: parts.ReasonTypeMiss,
+	})
+}
+
+func TestWalkDisabledSuffix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{
+		"10-enabled.conf",
+		"20-renamed.conf.disabled",
+		"30-marked.conf",
+		"30-marked.conf.disabled",
+	} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+
+	config := parts.NewDefaultConfig()
+	config.DisabledSuffix = ".disabled"
+	p := parts.NewParts([]string{dir}, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "10-enabled.conf")}, fileNames)
+
+	config.DisabledSuffix = ""
+	p = parts.NewParts([]string{dir}, config)
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Len(t, fileNames, 4)
+}
+
+func TestMerge(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+
+	base := parts.NewParts([]string{"testdata/test.conf", "testdata/etc"}, nil)
+	env := parts.NewParts([]string{"testdata/usr/lib"}, nil)
+	p := parts.Merge(base, env, config)
+	assert.Equal(t, testDataPaths, p.Paths)
+	assert.Equal(t, config, p.Config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	expected, err := parts.NewParts(testDataPaths, config).Readdirnames(0)
+	require.NoError(t, err)
+	assert.Equal(t, expected, fileNames)
+
+	// The per-path configurations are kept.
+	multi := parts.NewPartsMulti([]parts.PathConfig{{Path: "testdata/etc", Config: config}})
+	p = parts.Merge(multi, env, nil)
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Contains(t, fileNames, "testdata/usr/lib/40-noconf")
+	assert.Contains(t, fileNames, "testdata/etc/10-both.conf")
+}
+
+func TestWalkConcurrency(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	var expected []string
+	for i := 0; i < 100; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("%03d-file.conf", i))
+		require.NoError(t, ioutil.WriteFile(name, nil, 0644))
+		expected = append(expected, name)
+	}
+	require.NoError(t, os.Symlink(filepath.Join(dir, "notexist"), filepath.Join(dir, "500-broken.conf")))
+
+	config := parts.NewDefaultConfig()
+	config.SkipBrokenSymlinks = true
+	config.Concurrency = 8
+	p := parts.NewParts([]string{dir}, config)
+	for i := 0; i < 10; i++ {
+		fileNames, err := p.Readdirnames(0)
+		require.NoError(t, err)
+		assert.Equal(t, expected, fileNames)
+		assert.Len(t, p.Warnings(), 1)
+	}
+}
+
+func TestWalkCacheStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	firstFile := filepath.Join(dir, "10-first.conf")
+	secondFile := filepath.Join(dir, "20-second.conf")
+	require.NoError(t, ioutil.WriteFile(firstFile, nil, 0644))
+
+	config := parts.NewDefaultConfig()
+	config.CacheStats = true
+	p := parts.NewParts([]string{dir}, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{firstFile}, fileNames)
+
+	// Changing a file in place is seen even though the directory
+	// is unchanged.
+	require.NoError(t, os.Chmod(firstFile, 0))
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Empty(t, fileNames)
+
+	// Changing the directory invalidates the cache.
+	require.NoError(t, ioutil.WriteFile(secondFile, nil, 0644))
+	modTime := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(dir, modTime, modTime))
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{secondFile}, fileNames)
+
+	config.CacheStats = false
+	require.NoError(t, os.Chmod(firstFile, 0644))
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{firstFile, secondFile}, fileNames)
+}
+
+func TestReadCacheStatsContent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "10-a")
+	require.NoError(t, ioutil.WriteFile(file, []byte("old\n"), 0644))
+
+	config := parts.NewDefaultConfig()
+	config.CacheStats = true
+	config.ContentCacheBytes = 1024
+	p := parts.NewParts([]string{dir}, config)
+	b, err := p.ReadAll()
+	t.Logf("contents: %q, err: %v", b, err)
+	require.NoError(t, err)
+	assert.Equal(t, "old\n", string(b))
+
+	// Rewriting the file in place is seen by both caches.
+	modTime := time.Now().Add(time.Hour)
+	require.NoError(t, ioutil.WriteFile(file, []byte("new!\n"), 0644))
+	require.NoError(t, os.Chtimes(file, modTime, modTime))
+	b, err = p.ReadAll()
+	t.Logf("contents: %q, err: %v", b, err)
+	require.NoError(t, err)
+	assert.Equal(t, "new!\n", string(b))
+}
+
+func TestWalkReadDirChunkSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, subDir := range []string{"a", "b"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, subDir), 0755))
+	}
+	for i := 0; i < 5000; i++ {
+		name := fmt.Sprintf("%04d-file.conf", i)
+		if i%3 == 0 {
+			name = fmt.Sprintf("%04d-file.disabled", i)
+		}
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0644))
+		if i%100 == 0 {
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a", fmt.Sprintf("sub-%04d.conf", i)), nil, 0644))
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "b", fmt.Sprintf("sub-%04d.conf", i)), nil, 0644))
+		}
+	}
+
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	config.Recursive = true
+
+	p := parts.NewParts([]string{dir}, config)
+	expected, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	require.Len(t, expected, 3333+50)
+
+	config.ReadDirChunkSize = 64
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, expected, fileNames)
+	assert.Contains(t, fileNames, filepath.Join(dir, "a", "sub-0100.conf"))
+	assert.NotContains(t, fileNames, filepath.Join(dir, "b", "sub-0100.conf"))
+}
+
+func TestErrors(t *testing.T) {
+	p := parts.NewParts(nil, nil)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	assert.True(t, errors.Is(err, parts.ErrNoPaths))
+	assert.Empty(t, fileNames)
+
+	p = parts.NewParts([]string{"testdata/etc", "testdata/notexist"}, nil)
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	assert.True(t, errors.Is(err, parts.ErrPathNotFound))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	var pathErr *os.PathError
+	require.True(t, errors.As(err, &pathErr))
+	assert.Equal(t, "testdata/notexist", pathErr.Path)
+	assert.Empty(t, fileNames)
+
+	_, err = p.FindFirst("notexist.conf")
+	t.Logf("err: %v", err)
+	assert.True(t, errors.Is(err, parts.ErrPathNotFound))
+
+	p = parts.NewParts([]string{"testdata/etc"}, nil)
+	_, err = p.FindFirst("notexist.conf")
+	t.Logf("err: %v", err)
+	assert.True(t, errors.Is(err, parts.ErrNoMatch))
+	assert.False(t, errors.Is(err, parts.ErrPathNotFound))
+	_, err = p.Stat("notexist.conf")
+	t.Logf("err: %v", err)
+	assert.True(t, errors.Is(err, parts.ErrNoMatch))
+
+	// Filters that exclude every file are not an error.
+	config := parts.NewDefaultConfig()
+	config.GlobFilter = "*.notexist"
+	p = parts.NewParts([]string{"testdata/etc"}, config)
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Empty(t, fileNames)
+}
+
+func TestWalkLessFuncTies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	sameTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	names := []string{"b.conf", "d.conf", "a.conf", "c.conf", "e.conf"}
+	for i, name := range names {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, nil, 0644))
+		modTime := sameTime
+		if i == 0 {
+			modTime = sameTime.Add(-time.Minute)
+		}
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	config := parts.NewDefaultConfig()
+	config.LessFunc = func(a, b string) bool {
+		aInfo, aErr := os.Stat(a)
+		bInfo, bErr := os.Stat(b)
+		require.NoError(t, aErr)
+		require.NoError(t, bErr)
+		return aInfo.ModTime().Before(bInfo.ModTime())
+	}
+	p := parts.NewParts([]string{dir}, config)
+	expected := []string{
+		filepath.Join(dir, "b.conf"),
+		filepath.Join(dir, "a.conf"),
+		filepath.Join(dir, "c.conf"),
+		filepath.Join(dir, "d.conf"),
+		filepath.Join(dir, "e.conf"),
+	}
+	for i := 0; i < 10; i++ {
+		fileNames, err := p.Readdirnames(0)
+		require.NoError(t, err)
+		assert.Equal(t, expected, fileNames)
+	}
+
+	config.Reverse = true
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			filepath.Join(dir, "e.conf"),
+			filepath.Join(dir, "d.conf"),
+			filepath.Join(dir, "c.conf"),
+			filepath.Join(dir, "a.conf"),
+			filepath.Join(dir, "b.conf"),
+		},
+		fileNames)
+}
+
+func TestWalkKeepDuplicates(t *testing.T) {
+	config, err := parts.NewConfig(
+		false,
+		parts.DefaultModeTypeFilter,
+		parts.DefaultModePermFilter,
+		`\.conf$`)
+	t.Logf("config: %v", config)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	config.KeepDuplicates = true
+
+	p := parts.NewParts(testDataPaths, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	expected := []string{
+		"testdata/etc/10-both.conf",
+		"testdata/usr/lib/10-both.conf",
+		"testdata/etc/10-only-etc.conf",
+		"testdata/usr/lib/10-only-lib.conf",
+		"testdata/etc/20-only-etc.conf",
+		"testdata/usr/lib/20-only-lib.conf",
+		"testdata/etc/30-symlink.conf",
+		"testdata/usr/lib/30-symlink.conf",
+		"testdata/usr/lib/nodigits.conf",
+		"testdata/test.conf",
+	}
+	assert.Equal(t, expected, fileNames)
+
+	count, err := p.Count()
+	t.Logf("count: %d, err: %v", count, err)
+	require.NoError(t, err)
+	assert.Equal(t, len(expected), count)
+
+	path, ok := p.Lookup("10-both.conf")
+	assert.True(t, ok)
+	assert.Equal(t, "testdata/etc/10-both.conf", path)
+
+	config.Reverse = true
+	fileNames, err = p.Readdirnames(3)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"testdata/test.conf",
+			"testdata/usr/lib/nodigits.conf",
+			"testdata/etc/30-symlink.conf",
+		},
+		fileNames)
+}
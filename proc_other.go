@@ -0,0 +1,22 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+//go:build windows || plan9
+// +build windows plan9
+
+package parts
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup does nothing since process groups are not supported
+// on this platform.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the process started by cmd. Processes it
+// started are not killed on this platform.
+func killProcessGroup(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
+}
@@ -0,0 +1,250 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// readCloser combines a Reader with the Closer of the file it reads
+// from.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// closeAll closes all of files, skipping nil ones, even if some of
+// them fail to close. If exactly one fails, its error is returned;
+// if several fail, the returned error combines all of them and matches
+// each of them with errors.Is and errors.As.
+func closeAll(files []io.ReadCloser) error {
+	var errs []error
+	for _, file := range files {
+		if file == nil {
+			continue
+		}
+		if err := file.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return joinErrors(fmt.Sprintf("%d files failed to close", len(errs)), errs)
+}
+
+// gzipReadCloser decompresses the contents of a gzip compressed
+// file. Closing it closes both the gzip reader and the file.
+type gzipReadCloser struct {
+	*gzip.Reader
+	file io.Closer
+}
+
+// newGzipReadCloser returns a gzipReadCloser reading from file. The
+// file is closed if its gzip header is invalid.
+func newGzipReadCloser(file io.ReadCloser) (*gzipReadCloser, error) {
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return &gzipReadCloser{Reader: zr, file: file}, nil
+}
+
+func (g *gzipReadCloser) Close() error {
+	err := g.Reader.Close()
+	if closeErr := g.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// trimReader strips trailing spaces and tabs from each line read from
+// the underlying reader.
+type trimReader struct {
+	r   *bufio.Reader
+	buf []byte
+	err error
+}
+
+// newTrimReader returns a trimReader reading from r.
+func newTrimReader(r io.Reader) *trimReader {
+	return &trimReader{r: bufio.NewReader(r)}
+}
+
+func (t *trimReader) Read(b []byte) (int, error) {
+	for len(t.buf) == 0 {
+		if t.err != nil {
+			return 0, t.err
+		}
+		var line []byte
+		line, t.err = t.r.ReadBytes('\n')
+		t.buf = trimTrailingWhitespace(line)
+	}
+	n := copy(b, t.buf)
+	t.buf = t.buf[n:]
+
+	return n, nil
+}
+
+// trimTrailingWhitespace strips trailing spaces and tabs from line
+// while preserving its line ending.
+func trimTrailingWhitespace(line []byte) []byte {
+	var ending []byte
+	switch {
+	case bytes.HasSuffix(line, []byte("\r\n")):
+		ending = []byte("\r\n")
+	case bytes.HasSuffix(line, []byte("\n")):
+		ending = []byte("\n")
+	}
+	body := bytes.TrimRight(line[:len(line)-len(ending)], " \t")
+
+	return append(body, ending...)
+}
+
+// newlineReader ensures that the contents read from the underlying
+// reader end in exactly one line ending by removing any additional
+// trailing line endings or adding a "\n" if there is none. Nothing is
+// added to empty contents.
+type newlineReader struct {
+	r       io.Reader
+	held    []byte // Trailing '\r' and '\n' bytes not yet returned.
+	out     []byte // Output not yet returned.
+	started bool
+	err     error
+}
+
+func (n *newlineReader) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	for len(n.out) == 0 {
+		if n.err != nil {
+			return 0, n.err
+		}
+		chunk := make([]byte, len(b))
+		k, err := n.r.Read(chunk)
+		n.add(chunk[:k])
+		if err == io.EOF {
+			n.finish()
+		}
+		n.err = err
+	}
+	k := copy(b, n.out)
+	n.out = n.out[k:]
+
+	return k, nil
+}
+
+// add moves data to the output, holding back trailing line endings
+// until it is known whether they end the contents.
+func (n *newlineReader) add(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	n.started = true
+	body := bytes.TrimRight(data, "\r\n")
+	if len(body) > 0 {
+		n.out = append(n.out, n.held...)
+		n.out = append(n.out, body...)
+		n.held = n.held[:0]
+	}
+	n.held = append(n.held, data[len(body):]...)
+}
+
+// finish moves the first of the held line endings to the output, or
+// adds one if there is none.
+func (n *newlineReader) finish() {
+	if !n.started {
+		return
+	}
+	if i := bytes.IndexByte(n.held, '\n'); i >= 0 {
+		n.out = append(n.out, n.held[:i+1]...)
+	} else {
+		n.out = append(n.out, n.held...)
+		n.out = append(n.out, '\n')
+	}
+	n.held = nil
+}
+
+// fileReader reads the concatenation of readers like io.MultiReader
+// while keeping track of the file each of them belongs to, e.g., the
+// separator and header preceding a file belong to it. onStart, if not
+// nil, is called with the path of each file the first time bytes
+// belonging to it are read.
+type fileReader struct {
+	readers []io.Reader
+	paths   []string
+	onStart func(path string)
+	current string
+}
+
+// add appends r, belonging to the file at path, to the readers.
+func (r *fileReader) add(path string, reader io.Reader) {
+	r.readers = append(r.readers, reader)
+	r.paths = append(r.paths, path)
+}
+
+func (r *fileReader) Read(b []byte) (int, error) {
+	for len(r.readers) > 0 {
+		n, err := r.readers[0].Read(b)
+		if n > 0 && r.paths[0] != r.current {
+			r.current = r.paths[0]
+			if r.onStart != nil {
+				r.onStart(r.current)
+			}
+		}
+		if err == io.EOF {
+			r.readers = r.readers[1:]
+			r.paths = r.paths[1:]
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+
+	return 0, io.EOF
+}
+
+// path returns the path of the file being read, or of the next file
+// to be read if the current one has been exhausted. It returns false
+// if all of the files have been read. Whether a file has been
+// exhausted is found out by reading a byte ahead, which is returned
+// by the next Read.
+func (r *fileReader) path() (string, bool) {
+	for len(r.readers) > 0 {
+		var b [1]byte
+		n, err := r.readers[0].Read(b[:])
+		switch {
+		case n > 0:
+			r.readers[0] = io.MultiReader(bytes.NewReader(b[:n]), r.readers[0])
+		case err == io.EOF:
+			r.readers = r.readers[1:]
+			r.paths = r.paths[1:]
+			continue
+		case err != nil:
+			r.readers[0] = errReader{err: err}
+		}
+		return r.paths[0], true
+	}
+
+	return "", false
+}
+
+// errReader returns err from every Read, e.g., to report an error
+// encountered while reading ahead.
+type errReader struct {
+	err error
+}
+
+func (e errReader) Read([]byte) (int, error) {
+	return 0, e.err
+}
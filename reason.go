@@ -0,0 +1,73 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts
+
+// FilterReason describes why a file is excluded from the files in
+// paths, or that it is included.
+type FilterReason int
+
+const (
+	ReasonIncluded      FilterReason = iota // The file is included.
+	ReasonHidden                            // The name is hidden (ExcludeHidden).
+	ReasonNotLSBName                        // The name is not an LSB name (LSBNames).
+	ReasonDisabled                          // The file is disabled (DisabledSuffix).
+	ReasonExcludeMatch                      // The name matches one of ExcludeRegExps.
+	ReasonGlobMiss                          // The name does not match GlobFilter.
+	ReasonExtensionMiss                     // The extension is not one of Extensions.
+	ReasonRegexMiss                         // The name does not match RegExpFilter or IncludeRegExps.
+	ReasonPermMiss                          // The permissions do not match ModePermFilter.
+	ReasonTypeMiss                          // The type does not match ModeTypeFilter.
+	ReasonModTime                           // The modification time is out of range.
+	ReasonSize                              // The size is out of range.
+	ReasonOwner                             // The owner does not match OwnerUID or OwnerGID.
+	ReasonWorldWritable                     // The file is world-writable (RejectWorldWritable).
+	ReasonSetuid                            // The file is setuid or setgid (RejectSetuid).
+	ReasonBadSignature                      // The signature is missing or invalid (RequireSignature).
+	ReasonShadowed                          // A file with the same base name takes precedence.
+)
+
+var filterReasonStrings = [...]string{
+	ReasonIncluded:      "included",
+	ReasonHidden:        "hidden",
+	ReasonNotLSBName:    "not an LSB name",
+	ReasonDisabled:      "disabled",
+	ReasonExcludeMatch:  "matches an exclude regexp",
+	ReasonGlobMiss:      "does not match the glob",
+	ReasonExtensionMiss: "extension not allowed",
+	ReasonRegexMiss:     "does not match the regexp",
+	ReasonPermMiss:      "permissions do not match",
+	ReasonTypeMiss:      "type does not match",
+	ReasonModTime:       "modification time out of range",
+	ReasonSize:          "size out of range",
+	ReasonOwner:         "owner does not match",
+	ReasonWorldWritable: "world-writable",
+	ReasonSetuid:        "setuid or setgid",
+	ReasonBadSignature:  "missing or invalid signature",
+	ReasonShadowed:      "shadowed",
+}
+
+func (r FilterReason) String() string {
+	if r < 0 || int(r) >= len(filterReasonStrings) {
+		return "unknown"
+	}
+
+	return filterReasonStrings[r]
+}
+
+// Match reports whether a file in a directory with the given name and
+// mode passes the name and mode filters of c, and if not, why. The
+// name is matched as a base name, except by RegExpFilter if
+// RegExpMatchesFullPath is set, in which case it should be a full
+// path. A mode converted from an os.FileMode without ModeRegular is
+// treated as having it set if it describes a regular file. The filters
+// that need more information about the file, e.g., ModifiedAfter and
+// RequireSignature, are not checked.
+func (c *Config) Match(name string, mode FileMode) FilterReason {
+	if reason := c.nameReason(name); reason != ReasonIncluded {
+		return reason
+	}
+
+	return c.modeReason(mode.normalize())
+}
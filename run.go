@@ -0,0 +1,190 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// RunResult describes the execution of a file by Run.
+type RunResult struct {
+	// Path is the path of the file that was executed.
+	Path string
+
+	// ExitCode is the exit code of the process, or -1 if the
+	// process could not be started or was terminated by a signal.
+	ExitCode int
+
+	// Stdout and Stderr hold the output of the process.
+	Stdout []byte
+	Stderr []byte
+
+	// Err is the error returned when running the process, e.g., an
+	// *exec.ExitError if it exited with a non-zero exit code. It
+	// is nil if the process succeeded.
+	Err error
+}
+
+// RunError is returned by Run when a file fails and ExitOnError is
+// set.
+type RunError struct {
+	// Path is the path of the file that failed.
+	Path string
+
+	// ExitCode is the exit code of the process, or -1 if the
+	// process could not be started or was terminated by a signal.
+	ExitCode int
+
+	// Err is the error returned when running the process.
+	Err error
+}
+
+func (e *RunError) Error() string {
+	return fmt.Sprintf("parts: %s: exit code %d: %s", e.Path, e.ExitCode, e.Err)
+}
+
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
+
+// Run executes the files in paths in order, like run-parts does,
+// passing args as the arguments of each, like the --arg option of
+// run-parts. The processes are started with the file mode creation
+// mask set to Umask if SetUmask is set. Files that are not executable
+// are skipped. The processes run with env as their environment, or
+// with the environment of the current process if env is nil. The
+// output of each process is captured in its RunResult. A file failing
+// does not stop the remaining files from running unless ExitOnError is
+// set, in which case a *RunError identifying the file is returned
+// along with the results of the files that have run, including the
+// failed one. The process running when ctx is done is killed and the
+// remaining files are not run. Each process is also killed if it runs
+// for longer than PerFileTimeout. Run cannot be used with a Parts
+// traversing an fs.FS.
+func (p *Parts) Run(ctx context.Context, args []string, env []string) ([]RunResult, error) {
+	if p.fsys != nil {
+		return nil, errors.New("parts: cannot run files in an fs.FS")
+	}
+	if p.Config.SetUmask && !umaskSupported {
+		return nil, errors.New("parts: setting the umask is not supported on this platform")
+	}
+	if p.Config.SetUmask && (p.Config.Umask < 0 || p.Config.Umask > 0777) {
+		return nil, fmt.Errorf("parts: invalid umask %#o", p.Config.Umask)
+	}
+	entries, err := p.readdir(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	var results []RunResult
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		if !p.executable(e) {
+			continue
+		}
+		result := p.runFile(ctx, e.path, args, env)
+		results = append(results, result)
+		if result.Err == nil {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		if p.Config.ExitOnError {
+			return results, &RunError{Path: result.Path, ExitCode: result.ExitCode, Err: result.Err}
+		}
+	}
+
+	return results, nil
+}
+
+// executable reports whether e is a regular file, or a symbolic link
+// to one, that is executable by someone.
+func (p *Parts) executable(e entry) bool {
+	e, err := p.followEntry(e)
+
+	return err == nil && e.mode.IsExecutable()
+}
+
+// runFile executes the file at path with args and env and returns the
+// result. The process, along with any processes it started, is killed
+// if ctx is done or it runs for longer than PerFileTimeout unless it
+// is zero.
+func (p *Parts) runFile(ctx context.Context, path string, args []string, env []string) RunResult {
+	timeout := p.Config.PerFileTimeout
+	runCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	// Keep exec.Command from searching PATH for names without a
+	// directory.
+	name := path
+	if !strings.ContainsRune(name, filepath.Separator) {
+		name = "." + string(filepath.Separator) + name
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := p.command(name, args)
+	cmd.Env = env
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	setProcessGroup(cmd)
+	err := cmd.Start()
+	if err == nil {
+		err = wait(runCtx, cmd)
+	}
+	result := RunResult{
+		Path:     path,
+		ExitCode: -1,
+		Stdout:   stdout.Bytes(),
+		Stderr:   stderr.Bytes(),
+		Err:      err,
+	}
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	if err != nil && ctx.Err() == nil && runCtx.Err() == context.DeadlineExceeded {
+		result.Err = fmt.Errorf("timed out after %s: %w", timeout, runCtx.Err())
+	}
+
+	return result
+}
+
+// command returns the command executing the file name with args. If
+// SetUmask is set, the file is executed by a shell that sets the umask
+// first so that the umask of the current process is left unchanged.
+func (p *Parts) command(name string, args []string) *exec.Cmd {
+	if !p.Config.SetUmask {
+		return exec.Command(name, args...)
+	}
+	script := fmt.Sprintf(`umask %04o && exec "$0" "$@"`, p.Config.Umask)
+
+	return exec.Command(umaskShell, append([]string{"-c", script, name}, args...)...)
+}
+
+// wait waits for the started cmd to exit. If ctx is done first, the
+// process group of cmd is killed so that processes started by cmd
+// that hold its output open do not delay the return.
+func wait(ctx context.Context, cmd *exec.Cmd) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		killProcessGroup(cmd)
+		return <-done
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/apatters/go-parts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeScripts creates a temporary directory containing the given
+// executable shell scripts keyed by name. The caller must remove the
+// directory.
+func writeScripts(t *testing.T, scripts map[string]string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not supported")
+	}
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	for name, script := range scripts {
+		require.NoError(t, ioutil.WriteFile(
+			filepath.Join(dir, name),
+			[]byte("#!/bin/sh\n"+script+"\n"),
+			0755))
+	}
+
+	return dir
+}
+
+func TestRun(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"10-args":    `echo "$@"`,
+		"20-env":     `echo "$PARTS_TEST"`,
+		"30-fail":    `echo failed >&2; exit 3`,
+		"40-last":    `echo last`,
+		"50-notexec": `echo notexec`,
+	})
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.Chmod(filepath.Join(dir, "50-notexec"), 0644))
+
+	p := parts.NewParts([]string{dir}, nil)
+	results, err := p.Run(context.Background(), []string{"a", "b"}, []string{"PARTS_TEST=value"})
+	t.Logf("results: %+v", results)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	assert.Equal(t, filepath.Join(dir, "10-args"), results[0].Path)
+	assert.Equal(t, 0, results[0].ExitCode)
+	assert.Equal(t, "a b\n", string(results[0].Stdout))
+	assert.NoError(t, results[0].Err)
+
+	assert.Equal(t, filepath.Join(dir, "20-env"), results[1].Path)
+	assert.Equal(t, "value\n", string(results[1].Stdout))
+
+	assert.Equal(t, filepath.Join(dir, "30-fail"), results[2].Path)
+	assert.Equal(t, 3, results[2].ExitCode)
+	assert.Equal(t, "failed\n", string(results[2].Stderr))
+	assert.Error(t, results[2].Err)
+
+	assert.Equal(t, filepath.Join(dir, "40-last"), results[3].Path)
+	assert.Equal(t, "last\n", string(results[3].Stdout))
+}
+
+func TestRunExitOnError(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"10-first": `echo first`,
+		"20-fail":  `exit 1`,
+		"30-last":  `echo last`,
+	})
+	defer os.RemoveAll(dir)
+
+	config := parts.NewDefaultConfig()
+	config.ExitOnError = true
+	p := parts.NewParts([]string{dir}, config)
+	results, err := p.Run(context.Background(), nil, nil)
+	t.Logf("results: %+v", results)
+	t.Logf("err: %v", err)
+	require.Error(t, err)
+	var runErr *parts.RunError
+	require.True(t, errors.As(err, &runErr))
+	assert.Equal(t, filepath.Join(dir, "20-fail"), runErr.Path)
+	assert.Equal(t, 1, runErr.ExitCode)
+	var exitErr *exec.ExitError
+	assert.True(t, errors.As(err, &exitErr))
+	assert.Contains(t, err.Error(), filepath.Join(dir, "20-fail"))
+	assert.Contains(t, err.Error(), "exit code 1")
+	require.Len(t, results, 2)
+	assert.Equal(t, filepath.Join(dir, "10-first"), results[0].Path)
+	assert.Equal(t, filepath.Join(dir, "20-fail"), results[1].Path)
+	assert.Equal(t, 1, results[1].ExitCode)
+}
+
+func TestRunPerFileTimeout(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"10-hang": `exec sleep 60`,
+		"20-last": `echo last`,
+	})
+	defer os.RemoveAll(dir)
+
+	config := parts.NewDefaultConfig()
+	config.PerFileTimeout = 100 * time.Millisecond
+	p := parts.NewParts([]string{dir}, config)
+	start := time.Now()
+	results, err := p.Run(context.Background(), nil, nil)
+	t.Logf("results: %+v", results)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) < 30*time.Second)
+	require.Len(t, results, 2)
+	assert.Equal(t, -1, results[0].ExitCode)
+	assert.True(t, errors.Is(results[0].Err, context.DeadlineExceeded))
+	assert.NoError(t, results[1].Err)
+	assert.Equal(t, "last\n", string(results[1].Stdout))
+}
+
+func TestRunPerFileTimeoutSubprocess(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"10-hang": "sleep 60\necho done",
+		"20-last": `echo last`,
+	})
+	defer os.RemoveAll(dir)
+
+	config := parts.NewDefaultConfig()
+	config.PerFileTimeout = 200 * time.Millisecond
+	p := parts.NewParts([]string{dir}, config)
+	start := time.Now()
+	results, err := p.Run(context.Background(), nil, nil)
+	elapsed := time.Since(start)
+	t.Logf("results: %+v", results)
+	t.Logf("elapsed: %s, err: %v", elapsed, err)
+	require.NoError(t, err)
+	assert.True(t, elapsed < 10*time.Second)
+	require.Len(t, results, 2)
+	assert.True(t, errors.Is(results[0].Err, context.DeadlineExceeded))
+	assert.Empty(t, results[0].Stdout)
+	assert.Equal(t, "last\n", string(results[1].Stdout))
+}
+
+func TestRunUmask(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"10-umask": `umask`,
+	})
+	defer os.RemoveAll(dir)
+
+	config := parts.NewDefaultConfig()
+	config.SetUmask = true
+	config.Umask = 027
+	p := parts.NewParts([]string{dir}, config)
+	results, err := p.Run(context.Background(), nil, nil)
+	t.Logf("results: %+v", results)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "0027\n", string(results[0].Stdout))
+
+	config.Umask = 077
+	results, err = p.Run(context.Background(), nil, nil)
+	t.Logf("results: %+v", results)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "0077\n", string(results[0].Stdout))
+
+	config.Umask = 01000
+	results, err = p.Run(context.Background(), nil, nil)
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+	assert.Empty(t, results)
+
+	// The zero value leaves the mask unchanged, even with Umask 0.
+	config.SetUmask = false
+	config.Umask = 0
+	results, err = p.Run(context.Background(), nil, nil)
+	t.Logf("results: %+v", results)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, fmt.Sprintf("%04o\n", currentUmask(t)), string(results[0].Stdout))
+
+	p = parts.NewParts([]string{dir}, &parts.Config{
+		ModeTypeFilter: parts.DefaultModeTypeFilter,
+		ModePermFilter: parts.DefaultModePermFilter,
+	})
+	results, err = p.Run(context.Background(), nil, nil)
+	t.Logf("results: %+v", results)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, fmt.Sprintf("%04o\n", currentUmask(t)), string(results[0].Stdout))
+}
+
+// currentUmask returns the umask of the current process as reported
+// by the shell.
+func currentUmask(t *testing.T) int {
+	out, err := exec.Command("/bin/sh", "-c", "umask").Output()
+	require.NoError(t, err)
+	var mask int
+	_, err = fmt.Sscanf(string(out), "%o", &mask)
+	require.NoError(t, err)
+
+	return mask
+}
+
+func TestRunUmaskArgs(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"10-args": `printf '%s|' "$0" "$@"; exit 4`,
+	})
+	defer os.RemoveAll(dir)
+
+	config := parts.NewDefaultConfig()
+	config.SetUmask = true
+	config.Umask = 022
+	p := parts.NewParts([]string{dir}, config)
+	results, err := p.Run(context.Background(), []string{"a b", "$HOME", ""}, nil)
+	t.Logf("results: %+v", results)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, filepath.Join(dir, "10-args")+"|a b|$HOME||", string(results[0].Stdout))
+	assert.Equal(t, 4, results[0].ExitCode)
+}
+
+func TestRunContext(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"10-first": `echo first`,
+	})
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p := parts.NewParts([]string{dir}, nil)
+	results, err := p.Run(ctx, nil, nil)
+	t.Logf("results: %+v", results)
+	t.Logf("err: %v", err)
+	assert.Equal(t, context.Canceled, err)
+	assert.Empty(t, results)
+}
+
+func TestRunFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"etc/10-first": &fstest.MapFile{Data: []byte("#!/bin/sh\n"), Mode: 0755},
+	}
+	p := parts.NewPartsFS(fsys, []string{"etc"}, nil)
+	results, err := p.Run(context.Background(), nil, nil)
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+	assert.Empty(t, results)
+}
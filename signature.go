@@ -0,0 +1,60 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SignatureSuffix is appended to a file name to form the name of its
+// signature file.
+const SignatureSuffix = ".sig"
+
+// verifySignature returns true if the file described by e has a
+// valid signature according to c, and sets the verified contents of e
+// to the contents checked so that a file replaced after it is selected
+// is not read. An error is returned if the signature is missing or
+// invalid and FailOnBadSignature is set.
+func (p *Parts) verifySignature(c *Config, e *entry) (bool, error) {
+	if strings.HasSuffix(e.path, SignatureSuffix) {
+		return false, nil
+	}
+	if c.Verify == nil {
+		return false, errors.New("parts: RequireSignature is set but Verify is nil")
+	}
+	sig, err := p.readFile(e.path + SignatureSuffix)
+	switch {
+	case os.IsNotExist(err):
+		return p.badSignature(c, *e, "missing signature")
+	case err != nil:
+		return false, fmt.Errorf("parts: %w", err)
+	}
+	content, err := p.readFile(e.path)
+	if err != nil {
+		return false, fmt.Errorf("parts: %w", err)
+	}
+	if !c.Verify(content, sig) {
+		return p.badSignature(c, *e, "invalid signature")
+	}
+	if content == nil {
+		content = []byte{}
+	}
+	e.verified = content
+
+	return true, nil
+}
+
+// badSignature returns an error describing why the signature of the
+// file described by e was rejected if FailOnBadSignature is set.
+func (p *Parts) badSignature(c *Config, e entry, reason string) (bool, error) {
+	if c.FailOnBadSignature {
+		return false, fmt.Errorf("parts: %s: %s", e.path, reason)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apatters/go-parts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reverseSign returns a trivial "signature" of content for tests.
+func reverseSign(content []byte) []byte {
+	sig := make([]byte, len(content))
+	for i, b := range content {
+		sig[len(content)-1-i] = b
+	}
+	return sig
+}
+
+func TestRequireSignature(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	verified := filepath.Join(dir, "10-verified.conf")
+	unverified := filepath.Join(dir, "20-unverified.conf")
+	unsigned := filepath.Join(dir, "30-unsigned.conf")
+	require.NoError(t, ioutil.WriteFile(verified, []byte("verified\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(verified+parts.SignatureSuffix, reverseSign([]byte("verified\n")), 0644))
+	require.NoError(t, ioutil.WriteFile(unverified, []byte("unverified\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(unverified+parts.SignatureSuffix, []byte("bogus"), 0644))
+	require.NoError(t, ioutil.WriteFile(unsigned, []byte("unsigned\n"), 0644))
+
+	config := parts.NewDefaultConfig()
+	config.RequireSignature = true
+	config.Verify = func(content, sig []byte) bool {
+		return bytes.Equal(reverseSign(content), sig)
+	}
+	p := parts.NewParts([]string{dir}, config)
+	fileNames, err := p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	require.NoError(t, err)
+	assert.Equal(t, []string{verified}, fileNames)
+
+	config.FailOnBadSignature = true
+	fileNames, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	t.Logf("fileNames: %s", fileNames)
+	assert.Error(t, err)
+	assert.Empty(t, fileNames)
+
+	config.FailOnBadSignature = false
+	config.Verify = nil
+	_, err = p.Readdirnames(0)
+	t.Logf("err: %v", err)
+	assert.Error(t, err)
+}
+
+func TestRequireSignatureReadsVerifiedContent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	verified := filepath.Join(dir, "10-verified.conf")
+	require.NoError(t, ioutil.WriteFile(verified, []byte("verified\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(verified+parts.SignatureSuffix, reverseSign([]byte("verified\n")), 0644))
+
+	config := parts.NewDefaultConfig()
+	config.RequireSignature = true
+	config.Verify = func(content, sig []byte) bool {
+		ok := bytes.Equal(reverseSign(content), sig)
+		// Replace the file once it has been verified.
+		require.NoError(t, ioutil.WriteFile(verified, []byte("replaced\n"), 0644))
+		return ok
+	}
+	p := parts.NewParts([]string{dir}, config)
+	defer p.Close()
+	b, err := ioutil.ReadAll(p)
+	t.Logf("contents: %q", b)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, "verified\n", string(b))
+
+	require.NoError(t, ioutil.WriteFile(verified, []byte("verified\n"), 0644))
+	b, err = p.Bytes()
+	t.Logf("contents: %q", b)
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	assert.Equal(t, "verified\n", string(b))
+
+	require.NoError(t, ioutil.WriteFile(verified, []byte("verified\n"), 0644))
+	fragments, err := p.ContentMap()
+	t.Logf("err: %v", err)
+	require.NoError(t, err)
+	require.Len(t, fragments, 1)
+	assert.Equal(t, "verified\n", string(fragments[0].Content))
+}
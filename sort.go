@@ -0,0 +1,170 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package parts
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// SortKey selects the key used to sort files. See Config.SortBy.
+type SortKey int
+
+const (
+	// SortByName sorts files by name.
+	SortByName SortKey = iota
+	// SortByModTime sorts files by modification time.
+	SortByModTime
+	// SortBySize sorts files by size.
+	SortBySize
+)
+
+// lessFunc returns the function used to order entries when
+// sorting. Config.LessFunc is used if it is set, with ties broken by
+// comparing base names bytewise so that the order is deterministic,
+// otherwise the entries are compared by Config.SortBy, with ties
+// broken by name.
+func (p *Parts) lessFunc() func(a, b *entry) bool {
+	if p.Config.LessFunc != nil {
+		return func(a, b *entry) bool {
+			switch {
+			case p.Config.LessFunc(a.path, b.path):
+				return true
+			case p.Config.LessFunc(b.path, a.path):
+				return false
+			}
+			return filepath.Base(a.path) < filepath.Base(b.path)
+		}
+	}
+	lessName := p.lessNameFunc()
+	switch p.Config.SortBy {
+	case SortByModTime:
+		return func(a, b *entry) bool {
+			aTime, bTime := a.info.ModTime(), b.info.ModTime()
+			if !aTime.Equal(bTime) {
+				return aTime.Before(bTime)
+			}
+			return lessName(a, b)
+		}
+	case SortBySize:
+		return func(a, b *entry) bool {
+			if a.info.Size() != b.info.Size() {
+				return a.info.Size() < b.info.Size()
+			}
+			return lessName(a, b)
+		}
+	}
+
+	return lessName
+}
+
+// lessNameFunc returns the function used to order entries by name,
+// i.e., by their full paths if Config.SortByFullPath is set and by
+// their base names if not.
+func (p *Parts) lessNameFunc() func(a, b *entry) bool {
+	compare := p.compareFunc()
+	if p.Config.SortByFullPath {
+		return func(a, b *entry) bool {
+			return compare(a.path, b.path) < 0
+		}
+	}
+
+	return func(a, b *entry) bool {
+		return compare(filepath.Base(a.path), filepath.Base(b.path)) < 0
+	}
+}
+
+// compareFunc returns the function used to compare file base names
+// when sorting.
+func (p *Parts) compareFunc() func(a, b string) int {
+	compare := strings.Compare
+	if p.Config.Collator != nil {
+		compare = p.Config.Collator
+	}
+	if p.Config.CaseInsensitiveSort {
+		compare = compareFold(compare)
+	}
+	if p.Config.NumericSort {
+		return func(a, b string) int {
+			return compareNumeric(a, b, compare)
+		}
+	}
+
+	return compare
+}
+
+// compareFold returns a function that compares names using compare
+// after converting them to lower case. Names that differ only in case
+// are compared unchanged so that the order is deterministic.
+func compareFold(compare func(a, b string) int) func(a, b string) int {
+	return func(a, b string) int {
+		if c := compare(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+			return c
+		}
+
+		return compare(a, b)
+	}
+}
+
+// compareNumeric compares the leading decimal numbers of a and b
+// numerically and then the remainder of the names using
+// compare. Names without a leading number sort after names with one.
+func compareNumeric(a, b string, compare func(a, b string) int) int {
+	aNum, aRest := splitNumericPrefix(a)
+	bNum, bRest := splitNumericPrefix(b)
+	switch {
+	case aNum == "" && bNum == "":
+		return compare(a, b)
+	case aNum == "":
+		return 1
+	case bNum == "":
+		return -1
+	}
+	trimmedA := strings.TrimLeft(aNum, "0")
+	trimmedB := strings.TrimLeft(bNum, "0")
+	switch {
+	case len(trimmedA) < len(trimmedB):
+		return -1
+	case len(trimmedA) > len(trimmedB):
+		return 1
+	}
+	if c := strings.Compare(trimmedA, trimmedB); c != 0 {
+		return c
+	}
+	if c := compare(aRest, bRest); c != 0 {
+		return c
+	}
+
+	return compare(a, b)
+}
+
+// splitNumericPrefix splits name into its leading decimal digits and
+// the remainder.
+func splitNumericPrefix(name string) (string, string) {
+	i := 0
+	for i < len(name) && name[i] >= '0' && name[i] <= '9' {
+		i++
+	}
+
+	return name[:i], name[i:]
+}
+
+// entrySorter sorts entries using the less function.
+type entrySorter struct {
+	entries []entry
+	less    func(a, b *entry) bool
+}
+
+func (s entrySorter) Len() int {
+	return len(s.entries)
+}
+
+func (s entrySorter) Swap(i, j int) {
+	s.entries[i], s.entries[j] = s.entries[j], s.entries[i]
+}
+
+func (s entrySorter) Less(i, j int) bool {
+	return s.less(&s.entries[i], &s.entries[j])
+}
@@ -0,0 +1,16 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package parts
+
+// umaskSupported is true if the umask of the processes started by Run
+// can be set.
+const umaskSupported = true
+
+// umaskShell is the shell used to set the umask of the processes
+// started by Run.
+const umaskShell = "/bin/sh"